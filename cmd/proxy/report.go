@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"MITM_PROXY/pkg/report"
+	"MITM_PROXY/pkg/storage"
+)
+
+// runReport implements `mitm-proxy report [-format html|json|sarif] [-host h] [-severity s] [-o file]`.
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", "html", "report format: "+strings.Join(report.Formats, ", "))
+	host := fs.String("host", "", "only include findings for this host")
+	severity := fs.String("severity", "", "minimum severity: "+strings.Join(storage.Severities, ", "))
+	status := fs.String("status", "", "only include findings with this status")
+	out := fs.String("o", "", "output file (default stdout)")
+	dbURL := fs.String("dsn", dsn, "PostgreSQL connection string")
+	fs.Parse(args)
+
+	if *severity != "" && storage.SeverityRank(*severity) < 0 {
+		log.Fatalf("unknown severity %q", *severity)
+	}
+
+	if err := storage.Init(*dbURL); err != nil {
+		log.Fatalf("DB init failed: %v", err)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("cannot create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	filter := storage.FindingFilter{Host: *host, MinSeverity: *severity, Status: *status}
+	if err := report.Generate(w, *format, filter); err != nil {
+		log.Fatalf("report failed: %v", err)
+	}
+	if *out != "" {
+		fmt.Fprintln(os.Stderr, "Report written to", *out)
+	}
+}
@@ -1,15 +1,49 @@
+CREATE TABLE IF NOT EXISTS tls_connections (
+  id                 SERIAL PRIMARY KEY,
+  host               TEXT      NOT NULL,
+  sni                TEXT      NOT NULL DEFAULT '',
+  version            TEXT      NOT NULL DEFAULT '',
+  cipher_suite       TEXT      NOT NULL DEFAULT '',
+  alpn               TEXT      NOT NULL DEFAULT '',
+  offered_versions   TEXT[]    NOT NULL DEFAULT '{}',
+  offered_ciphers    TEXT[]    NOT NULL DEFAULT '{}',
+  offered_alpn       TEXT[]    NOT NULL DEFAULT '{}',
+  ja3                TEXT      NOT NULL DEFAULT '',
+  ja3_hash           TEXT      NOT NULL DEFAULT '',
+  ja4                TEXT      NOT NULL DEFAULT '',
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tls_connections_sni ON tls_connections(sni);
+CREATE INDEX IF NOT EXISTS idx_tls_connections_ja3 ON tls_connections(ja3_hash);
+CREATE INDEX IF NOT EXISTS idx_tls_connections_ja4 ON tls_connections(ja4);
+
 CREATE TABLE IF NOT EXISTS requests (
   id           SERIAL PRIMARY KEY,
   method       TEXT      NOT NULL,
+  scheme       TEXT      NOT NULL DEFAULT 'http',
+  host         TEXT      NOT NULL DEFAULT '',
   path         TEXT      NOT NULL,
   query_params JSONB     NOT NULL DEFAULT '{}'::jsonb,
   headers      JSONB     NOT NULL DEFAULT '{}'::jsonb,
   cookies      JSONB     NOT NULL DEFAULT '{}'::jsonb,
   post_params  JSONB     NOT NULL DEFAULT '{}'::jsonb,
   body         TEXT      NOT NULL DEFAULT '',
+  tls_connection_id INTEGER REFERENCES tls_connections(id) ON DELETE SET NULL,
+  protocol     TEXT      NOT NULL DEFAULT '',
+  pseudo_headers JSONB   NOT NULL DEFAULT '{}'::jsonb,
+  trailers     JSONB     NOT NULL DEFAULT '{}'::jsonb,
   created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Columns added since the table was first created, for existing databases
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS scheme TEXT NOT NULL DEFAULT 'http';
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS host   TEXT NOT NULL DEFAULT '';
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS tls_connection_id INTEGER REFERENCES tls_connections(id) ON DELETE SET NULL;
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS protocol       TEXT  NOT NULL DEFAULT '';
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS pseudo_headers JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE requests ADD COLUMN IF NOT EXISTS trailers       JSONB NOT NULL DEFAULT '{}'::jsonb;
+
 CREATE TABLE IF NOT EXISTS responses (
   id             SERIAL PRIMARY KEY,
   request_id     INTEGER   NOT NULL REFERENCES requests(id) ON DELETE CASCADE,
@@ -17,8 +51,253 @@ CREATE TABLE IF NOT EXISTS responses (
   status_message TEXT      NOT NULL,
   headers        JSONB     NOT NULL DEFAULT '{}'::jsonb,
   body           TEXT      NOT NULL DEFAULT '',
+  protocol       TEXT      NOT NULL DEFAULT '',
+  pseudo_headers JSONB     NOT NULL DEFAULT '{}'::jsonb,
+  trailers       JSONB     NOT NULL DEFAULT '{}'::jsonb,
+  streamed       BOOLEAN   NOT NULL DEFAULT FALSE,
+  truncated      BOOLEAN   NOT NULL DEFAULT FALSE,
   created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Columns added since the table was first created, for existing databases
+ALTER TABLE responses ADD COLUMN IF NOT EXISTS protocol       TEXT  NOT NULL DEFAULT '';
+ALTER TABLE responses ADD COLUMN IF NOT EXISTS pseudo_headers JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE responses ADD COLUMN IF NOT EXISTS trailers       JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE responses ADD COLUMN IF NOT EXISTS streamed       BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE responses ADD COLUMN IF NOT EXISTS truncated      BOOLEAN NOT NULL DEFAULT FALSE;
+
 CREATE INDEX IF NOT EXISTS idx_requests_created_at ON requests(created_at);
 CREATE INDEX IF NOT EXISTS idx_responses_request_id ON responses(request_id);
+
+CREATE TABLE IF NOT EXISTS findings (
+  id          SERIAL PRIMARY KEY,
+  dedup_key   TEXT      NOT NULL UNIQUE,
+  source      TEXT      NOT NULL,
+  type        TEXT      NOT NULL,
+  severity    TEXT      NOT NULL,
+  confidence  TEXT      NOT NULL DEFAULT 'firm',
+  host        TEXT      NOT NULL DEFAULT '',
+  path        TEXT      NOT NULL DEFAULT '',
+  parameter   TEXT      NOT NULL DEFAULT '',
+  detail      TEXT      NOT NULL DEFAULT '',
+  evidence    TEXT      NOT NULL DEFAULT '',
+  request_id  INTEGER   REFERENCES requests(id) ON DELETE SET NULL,
+  response_id INTEGER   REFERENCES responses(id) ON DELETE SET NULL,
+  status      TEXT      NOT NULL DEFAULT 'open',
+  occurrences INTEGER   NOT NULL DEFAULT 1,
+  first_seen  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_seen   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_findings_request_id ON findings(request_id);
+CREATE INDEX IF NOT EXISTS idx_findings_host ON findings(host);
+
+-- Every exchange that showed a finding; findings.request_id is the latest
+CREATE TABLE IF NOT EXISTS finding_occurrences (
+  id          SERIAL PRIMARY KEY,
+  finding_id  INTEGER   NOT NULL REFERENCES findings(id) ON DELETE CASCADE,
+  request_id  INTEGER   NOT NULL REFERENCES requests(id) ON DELETE CASCADE,
+  response_id INTEGER   REFERENCES responses(id) ON DELETE SET NULL,
+  seen_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_finding_occurrences_finding_id ON finding_occurrences(finding_id);
+CREATE INDEX IF NOT EXISTS idx_finding_occurrences_request_id ON finding_occurrences(request_id);
+
+CREATE TABLE IF NOT EXISTS scan_jobs (
+  id          SERIAL PRIMARY KEY,
+  request_id  INTEGER   NOT NULL REFERENCES requests(id) ON DELETE CASCADE,
+  kind        TEXT      NOT NULL,
+  status      TEXT      NOT NULL DEFAULT 'queued',
+  progress    INTEGER   NOT NULL DEFAULT 0,
+  findings    INTEGER   NOT NULL DEFAULT 0,
+  error       TEXT      NOT NULL DEFAULT '',
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  started_at  TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_jobs_status ON scan_jobs(status);
+
+CREATE TABLE IF NOT EXISTS rewrite_rules (
+  id          SERIAL PRIMARY KEY,
+  name        TEXT      NOT NULL DEFAULT '',
+  enabled     BOOLEAN   NOT NULL DEFAULT TRUE,
+  phase       TEXT      NOT NULL,
+  host        TEXT      NOT NULL DEFAULT '',
+  path        TEXT      NOT NULL DEFAULT '',
+  method      TEXT      NOT NULL DEFAULT '',
+  action      TEXT      NOT NULL,
+  header      TEXT      NOT NULL DEFAULT '',
+  match       TEXT      NOT NULL DEFAULT '',
+  value       TEXT      NOT NULL DEFAULT '',
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS scripts (
+  id          SERIAL PRIMARY KEY,
+  name        TEXT      NOT NULL DEFAULT '',
+  enabled     BOOLEAN   NOT NULL DEFAULT TRUE,
+  source      TEXT      NOT NULL,
+  timeout_ms  INTEGER   NOT NULL DEFAULT 1000,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS map_rules (
+  id          SERIAL PRIMARY KEY,
+  name        TEXT      NOT NULL DEFAULT '',
+  enabled     BOOLEAN   NOT NULL DEFAULT TRUE,
+  kind        TEXT      NOT NULL,
+  host        TEXT      NOT NULL DEFAULT '',
+  path        TEXT      NOT NULL DEFAULT '',
+  method      TEXT      NOT NULL DEFAULT '',
+  local_path  TEXT      NOT NULL DEFAULT '',
+  status      INTEGER   NOT NULL DEFAULT 200,
+  headers     JSONB     NOT NULL DEFAULT '{}'::jsonb,
+  target      TEXT      NOT NULL DEFAULT '',
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS mocks (
+  id          SERIAL PRIMARY KEY,
+  name        TEXT      NOT NULL DEFAULT '',
+  enabled     BOOLEAN   NOT NULL DEFAULT TRUE,
+  request_id  INTEGER   REFERENCES requests(id) ON DELETE SET NULL,
+  method      TEXT      NOT NULL DEFAULT '',
+  host        TEXT      NOT NULL DEFAULT '',
+  path        TEXT      NOT NULL DEFAULT '',
+  query       TEXT      NOT NULL DEFAULT '',
+  match_body  TEXT      NOT NULL DEFAULT '',
+  status      INTEGER   NOT NULL DEFAULT 200,
+  headers     JSONB     NOT NULL DEFAULT '{}'::jsonb,
+  body        TEXT      NOT NULL DEFAULT '',
+  template    BOOLEAN   NOT NULL DEFAULT FALSE,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS breakpoints (
+  id          SERIAL PRIMARY KEY,
+  enabled     BOOLEAN   NOT NULL DEFAULT TRUE,
+  host        TEXT      NOT NULL DEFAULT '',
+  path        TEXT      NOT NULL DEFAULT '',
+  method      TEXT      NOT NULL DEFAULT '',
+  min_status  INTEGER   NOT NULL DEFAULT 0,
+  max_status  INTEGER   NOT NULL DEFAULT 0,
+  json_field  TEXT      NOT NULL DEFAULT '',
+  json_value  TEXT      NOT NULL DEFAULT '',
+  body        TEXT      NOT NULL DEFAULT '',
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS network_rules (
+  id            SERIAL PRIMARY KEY,
+  name          TEXT      NOT NULL DEFAULT '',
+  enabled       BOOLEAN   NOT NULL DEFAULT TRUE,
+  host          TEXT      NOT NULL DEFAULT '',
+  path          TEXT      NOT NULL DEFAULT '',
+  method        TEXT      NOT NULL DEFAULT '',
+  latency_ms    INTEGER   NOT NULL DEFAULT 0,
+  jitter_ms     INTEGER   NOT NULL DEFAULT 0,
+  upload_kbps   INTEGER   NOT NULL DEFAULT 0,
+  download_kbps INTEGER   NOT NULL DEFAULT 0,
+  reset_rate    DOUBLE PRECISION NOT NULL DEFAULT 0,
+  error_rate    DOUBLE PRECISION NOT NULL DEFAULT 0,
+  error_status  INTEGER   NOT NULL DEFAULT 503,
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS block_rules (
+  id          SERIAL PRIMARY KEY,
+  name        TEXT      NOT NULL DEFAULT '',
+  enabled     BOOLEAN   NOT NULL DEFAULT TRUE,
+  kind        TEXT      NOT NULL,
+  pattern     TEXT      NOT NULL,
+  action      TEXT      NOT NULL DEFAULT 'block',
+  status      INTEGER   NOT NULL DEFAULT 403,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS scope_rules (
+  id            SERIAL PRIMARY KEY,
+  name          TEXT      NOT NULL DEFAULT '',
+  enabled       BOOLEAN   NOT NULL DEFAULT TRUE,
+  kind          TEXT      NOT NULL,
+  host          TEXT      NOT NULL DEFAULT '',
+  path          TEXT      NOT NULL DEFAULT '',
+  extensions    TEXT      NOT NULL DEFAULT '',
+  content_type  TEXT      NOT NULL DEFAULT '',
+  pass_through  BOOLEAN   NOT NULL DEFAULT FALSE,
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS tls_passthrough (
+  id          SERIAL PRIMARY KEY,
+  host        TEXT      NOT NULL UNIQUE,
+  reason      TEXT      NOT NULL DEFAULT 'manual',
+  expires_at  TIMESTAMPTZ,
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A single row set through PUT /upstream-tls
+CREATE TABLE IF NOT EXISTS upstream_tls_config (
+  id             BOOLEAN   PRIMARY KEY DEFAULT TRUE CHECK (id),
+  ca_bundle      TEXT      NOT NULL DEFAULT '',
+  insecure_hosts TEXT[]    NOT NULL DEFAULT '{}',
+  fingerprint    TEXT      NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS tunnels (
+  id           SERIAL PRIMARY KEY,
+  host         TEXT      NOT NULL,
+  sni          TEXT      NOT NULL DEFAULT '',
+  reason       TEXT      NOT NULL DEFAULT '',
+  started_at   TIMESTAMPTZ NOT NULL,
+  duration_ms  BIGINT    NOT NULL DEFAULT 0,
+  bytes_up     BIGINT    NOT NULL DEFAULT 0,
+  bytes_down   BIGINT    NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_tunnels_host ON tunnels(host);
+
+CREATE TABLE IF NOT EXISTS grpc_messages (
+  id           SERIAL PRIMARY KEY,
+  request_id   INTEGER   NOT NULL REFERENCES requests(id) ON DELETE CASCADE,
+  method       TEXT      NOT NULL,
+  direction    TEXT      NOT NULL,
+  seq          INTEGER   NOT NULL,
+  compressed   BOOLEAN   NOT NULL DEFAULT FALSE,
+  data         BYTEA     NOT NULL,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_grpc_messages_request_id ON grpc_messages(request_id);
+
+CREATE TABLE IF NOT EXISTS websocket_messages (
+  id           SERIAL PRIMARY KEY,
+  request_id   INTEGER   NOT NULL REFERENCES requests(id) ON DELETE CASCADE,
+  direction    TEXT      NOT NULL,
+  type         TEXT      NOT NULL,
+  data         BYTEA     NOT NULL,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_websocket_messages_request_id ON websocket_messages(request_id);
+
+CREATE TABLE IF NOT EXISTS sse_events (
+  id           SERIAL PRIMARY KEY,
+  request_id   INTEGER   NOT NULL REFERENCES requests(id) ON DELETE CASCADE,
+  event_id     TEXT      NOT NULL DEFAULT '',
+  type         TEXT      NOT NULL DEFAULT '',
+  data         TEXT      NOT NULL,
+  retry        INTEGER,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sse_events_request_id ON sse_events(request_id);
+
+CREATE TABLE IF NOT EXISTS grpc_descriptors (
+  id           SERIAL PRIMARY KEY,
+  name         TEXT      NOT NULL DEFAULT '',
+  data         BYTEA     NOT NULL,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
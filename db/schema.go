@@ -0,0 +1,8 @@
+// Package db holds the database schema. init.sql can be run on a new or
+// an existing database alike; storage.Init applies it on every start.
+package db
+
+import _ "embed"
+
+//go:embed init.sql
+var Schema string
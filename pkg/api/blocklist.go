@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/blocklist"
+	"MITM_PROXY/pkg/storage"
+)
+
+type blockRuleInfo struct {
+	storage.BlockRule
+	Hits int64 `json:"hits"`
+}
+
+// blockRules serves GET /blocklist and POST /blocklist.
+func blockRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListBlockRules()
+		if err != nil {
+			http.Error(w, "Failed to get block rules", http.StatusInternalServerError)
+			return
+		}
+		counters := blocklist.GetCounters()
+		infos := make([]blockRuleInfo, 0, len(list))
+		for _, rule := range list {
+			infos = append(infos, blockRuleInfo{BlockRule: rule, Hits: counters.Rules[rule.ID]})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(infos)
+
+	case http.MethodPost:
+		rule := storage.BlockRule{Enabled: true, Action: blocklist.ActionBlock, Status: http.StatusForbidden}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Bad block rule", http.StatusBadRequest)
+			return
+		}
+		if err := blocklist.Validate(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.CreateBlockRule(&rule); err != nil {
+			http.Error(w, "Failed to save block rule", http.StatusInternalServerError)
+			return
+		}
+		reloadBlocklist()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// blockRuleByID serves GET, PUT and DELETE /blocklist/{id}, and
+// GET/DELETE /blocklist/counters to read or reset the hit counters.
+func blockRuleByID(w http.ResponseWriter, r *http.Request) {
+	if strings.TrimPrefix(r.URL.Path, "/blocklist/") == "counters" {
+		blockCounters(w, r)
+		return
+	}
+
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/blocklist/"), "%d", &id); err != nil {
+		http.Error(w, "Bad block rule ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := storage.GetBlockRule(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodPut:
+		var rule storage.BlockRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Bad block rule", http.StatusBadRequest)
+			return
+		}
+		rule.ID = id
+		if err := blocklist.Validate(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.UpdateBlockRule(&rule); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadBlocklist()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodDelete:
+		if err := storage.DeleteBlockRule(id); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadBlocklist()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT and DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func blockCounters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(blocklist.GetCounters())
+	case http.MethodDelete:
+		blocklist.ResetCounters()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Only GET and DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func reloadBlocklist() {
+	if err := blocklist.Load(); err != nil {
+		log.Println("Error reloading block rules:", err)
+	}
+}
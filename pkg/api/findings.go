@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// listFindings serves GET /findings?host=&severity=&status=&type=
+func listFindings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := storage.FindingFilter{
+		Host:        q.Get("host"),
+		MinSeverity: q.Get("severity"),
+		Status:      q.Get("status"),
+		Type:        q.Get("type"),
+	}
+	if filter.MinSeverity != "" && storage.SeverityRank(filter.MinSeverity) < 0 {
+		http.Error(w, "Unknown severity", http.StatusBadRequest)
+		return
+	}
+
+	findings, err := storage.ListFindings(filter)
+	if err != nil {
+		http.Error(w, "Failed to get findings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(findings)
+}
+
+// findingByID serves GET /findings/{id} and POST /findings/{id}/status.
+func findingByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/findings/"), "/")
+	var id int
+	if _, err := fmt.Sscanf(parts[0], "%d", &id); err != nil {
+		http.Error(w, "Bad finding ID", http.StatusBadRequest)
+		return
+	}
+
+	if len(parts) > 1 && parts[1] == "status" {
+		updateFindingStatus(w, r, id)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	finding, err := storage.GetFindingByID(id)
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(finding)
+}
+
+func updateFindingStatus(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !storage.ValidStatus(body.Status) {
+		http.Error(w, "Status must be one of open, false-positive, fixed", http.StatusBadRequest)
+		return
+	}
+
+	if err := storage.UpdateFindingStatus(id, body.Status); err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	finding, err := storage.GetFindingByID(id)
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(finding)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/grpc"
+	"MITM_PROXY/pkg/storage"
+)
+
+// maxDescriptorSet caps uploaded descriptor sets.
+const maxDescriptorSet = 16 << 20
+
+// grpcDescriptorSets serves GET /grpc-descriptors and POST
+// /grpc-descriptors?name=, whose body is a binary FileDescriptorSet as
+// written by protoc --descriptor_set_out --include_imports.
+func grpcDescriptorSets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListGRPCDescriptorSets()
+		if err != nil {
+			http.Error(w, "Failed to get descriptor sets", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDescriptorSet))
+		if err != nil {
+			http.Error(w, "Bad descriptor set", http.StatusBadRequest)
+			return
+		}
+		if err := grpc.Validate(data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s, err := grpc.Add(r.URL.Query().Get("name"), data)
+		if err != nil {
+			http.Error(w, "Failed to save descriptor set", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// grpcDescriptorSetByID serves DELETE /grpc-descriptors/{id}.
+func grpcDescriptorSetByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Only DELETE allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/grpc-descriptors/"), "%d", &id); err != nil {
+		http.Error(w, "Bad descriptor set ID", http.StatusBadRequest)
+		return
+	}
+	if err := grpc.Remove(id); err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
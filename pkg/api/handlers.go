@@ -8,16 +8,26 @@ import (
 	"net/http"
 	"strings"
 
+	"MITM_PROXY/pkg/grpc"
+	"MITM_PROXY/pkg/scanner"
 	"MITM_PROXY/pkg/storage"
 )
 
+// getAllRequests serves GET /requests, optionally filtered by a client
+// TLS fingerprint with ?fingerprint= (JA3 hash or string, or JA4).
 func getAllRequests(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	requests, err := storage.GetAllRequests()
+	var requests []storage.RequestInfo
+	var err error
+	if fp := r.URL.Query().Get("fingerprint"); fp != "" {
+		requests, err = storage.GetRequestsByFingerprint(fp)
+	} else {
+		requests, err = storage.GetAllRequests()
+	}
 	if err != nil {
 		http.Error(w, "Failed to get requests", http.StatusInternalServerError)
 		return
@@ -46,6 +56,50 @@ func getRequestByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(parts) > 1 && parts[1] == "findings" {
+		findings, err := storage.GetFindingsByRequestID(id)
+		if err != nil {
+			http.Error(w, "Failed to get findings", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(findings)
+		return
+	}
+
+	if len(parts) > 1 && parts[1] == "grpc" {
+		msgs, err := grpc.Messages(id)
+		if err != nil {
+			http.Error(w, "Failed to get gRPC messages", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(msgs)
+		return
+	}
+
+	if len(parts) > 1 && parts[1] == "websocket" {
+		msgs, err := storage.ListWebSocketMessages(id)
+		if err != nil {
+			http.Error(w, "Failed to get WebSocket messages", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(websocketMessages(msgs))
+		return
+	}
+
+	if len(parts) > 1 && parts[1] == "sse" {
+		events, err := storage.ListSSEEvents(id)
+		if err != nil {
+			http.Error(w, "Failed to get SSE events", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+		return
+	}
+
 	reqInfo, err := storage.GetRequestByID(id)
 	if err != nil || reqInfo == nil {
 		http.Error(w, "Not found", http.StatusNotFound)
@@ -155,55 +209,67 @@ func scanRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Perform basic security checks
-	issues := make([]string, 0)
+	result := scanner.ScanBasic(req)
+	findings := scanner.SaveFindings(id, req, result.Findings)
 
-	// Check for SQL injection patterns in URL parameters
-	for _, values := range req.URL.Query() {
-		for _, value := range values {
-			if strings.Contains(strings.ToLower(value), "select ") ||
-				strings.Contains(strings.ToLower(value), "insert ") ||
-				strings.Contains(strings.ToLower(value), "update ") ||
-				strings.Contains(strings.ToLower(value), "delete ") {
-				issues = append(issues, "Possible SQL injection in URL parameters")
-				break
-			}
-		}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":       id,
+		"issues":   result.Issues,
+		"findings": findings,
+	})
+}
+
+func scanXXE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Check for XSS patterns
-	bodyBytes, err := io.ReadAll(req.Body)
-	if err == nil {
-		req.Body = io.NopCloser(bytes.NewReader(bodyBytes)) // Reset body reader
-		bodyStr := string(bodyBytes)
-		if strings.Contains(bodyStr, "<script>") || strings.Contains(bodyStr, "javascript:") {
-			issues = append(issues, "Possible XSS in request body")
-		}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/scan-xxe/"), "/")
+	if len(parts) < 1 || parts[0] == "" {
+		http.Error(w, "Bad request ID", http.StatusBadRequest)
+		return
 	}
 
-	// Check for sensitive headers
-	if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
-		issues = append(issues, "Request contains sensitive headers")
+	idStr := parts[0]
+	var id int
+	_, err := fmt.Sscanf(idStr, "%d", &id)
+	if err != nil {
+		http.Error(w, "Bad request ID", http.StatusBadRequest)
+		return
 	}
 
-	if len(issues) == 0 {
-		issues = append(issues, "No obvious security issues found")
+	req, err := storage.GetRequestByID(id)
+	if err != nil || req == nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
 	}
 
+	result, err := scanner.ScanXXE(r.Context(), req)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+		return
+	}
+	findings := scanner.SaveFindings(id, req, result.Findings)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":     id,
-		"issues": issues,
+		"id":         id,
+		"is_xml":     result.IsXML,
+		"vulnerable": result.Vulnerable,
+		"details":    result.Details,
+		"findings":   findings,
 	})
 }
 
-func scanXXE(w http.ResponseWriter, r *http.Request) {
+func scanSSRF(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/scan-xxe/"), "/")
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/scan-ssrf/"), "/")
 	if len(parts) < 1 || parts[0] == "" {
 		http.Error(w, "Bad request ID", http.StatusBadRequest)
 		return
@@ -223,51 +289,19 @@ func scanXXE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bodyBytes, err := io.ReadAll(req.Body)
+	result, err := scanner.ScanSSRF(r.Context(), req)
 	if err != nil {
-		http.Error(w, "Failed to read request body", http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("SSRF scan failed: %v", err), http.StatusInternalServerError)
 		return
 	}
-	req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-	bodyStr := string(bodyBytes)
-
-	result := map[string]interface{}{
-		"id":         id,
-		"is_xml":     false,
-		"vulnerable": false,
-		"details":    "",
-	}
-
-	if strings.Contains(bodyStr, "<?xml") {
-		result["is_xml"] = true
-
-		xxePayload := `<!DOCTYPE foo [
-            <!ELEMENT foo ANY >
-            <!ENTITY xxe SYSTEM "file:///etc/passwd" >]>
-            <foo>&xxe;</foo>`
-
-		modifiedBody := strings.Replace(bodyStr, "<?xml", xxePayload, 1)
-
-		client := &http.Client{}
-		newReq, err := http.NewRequest(req.Method, req.URL.String(), strings.NewReader(modifiedBody))
-		if err == nil {
-			for k, v := range req.Header {
-				newReq.Header[k] = v
-			}
-
-			resp, err := client.Do(newReq)
-			if err == nil {
-				defer resp.Body.Close()
-				respBody, _ := io.ReadAll(resp.Body)
-
-				if strings.Contains(string(respBody), "root:") {
-					result["vulnerable"] = true
-					result["details"] = "System file /etc/passwd was leaked through XXE"
-				}
-			}
-		}
-	}
+	findings := scanner.SaveFindings(id, req, result.Findings)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         id,
+		"candidates": result.Candidates,
+		"vulnerable": result.Vulnerable,
+		"hits":       result.Hits,
+		"findings":   findings,
+	})
 }
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/intercept"
+	"MITM_PROXY/pkg/storage"
+)
+
+// interceptQueue serves GET /intercept with the items waiting for a decision.
+func interceptQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(intercept.Pending())
+}
+
+// interceptItem serves GET/PUT /intercept/config, /intercept/breakpoints,
+// POST /intercept/{id}/forward (optionally with edits) and POST /intercept/{id}/drop.
+func interceptItem(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/intercept/"), "/")
+	switch parts[0] {
+	case "config":
+		interceptConfig(w, r)
+		return
+	case "breakpoints":
+		if len(parts) > 1 && parts[1] != "" {
+			breakpointByID(w, r, parts[1])
+		} else {
+			breakpoints(w, r)
+		}
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var id int
+	if _, err := fmt.Sscanf(parts[0], "%d", &id); err != nil || len(parts) < 2 {
+		http.Error(w, "Expected /intercept/{id}/forward or /intercept/{id}/drop", http.StatusBadRequest)
+		return
+	}
+
+	var d intercept.Decision
+	switch parts[1] {
+	case "forward":
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+				http.Error(w, "Bad decision body", http.StatusBadRequest)
+				return
+			}
+		}
+		d.Action = intercept.ActionForward
+	case "drop":
+		d.Action = intercept.ActionDrop
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	if err := intercept.Decide(id, d); err != nil {
+		if errors.Is(err, intercept.ErrNotFound) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func interceptConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var c intercept.Config
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			http.Error(w, "Bad config", http.StatusBadRequest)
+			return
+		}
+		if err := intercept.SetConfig(c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Only GET and PUT allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(intercept.GetConfig())
+}
+
+// breakpoints serves GET and POST /intercept/breakpoints.
+func breakpoints(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListBreakpoints()
+		if err != nil {
+			http.Error(w, "Failed to get breakpoints", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		b := storage.Breakpoint{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			http.Error(w, "Bad breakpoint", http.StatusBadRequest)
+			return
+		}
+		if err := intercept.ValidateBreakpoint(b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.CreateBreakpoint(&b); err != nil {
+			http.Error(w, "Failed to save breakpoint", http.StatusInternalServerError)
+			return
+		}
+		reloadBreakpoints()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(b)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// breakpointByID serves GET, PUT and DELETE /intercept/breakpoints/{id}.
+func breakpointByID(w http.ResponseWriter, r *http.Request, idStr string) {
+	var id int
+	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+		http.Error(w, "Bad breakpoint ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		b, err := storage.GetBreakpoint(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b)
+
+	case http.MethodPut:
+		var b storage.Breakpoint
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			http.Error(w, "Bad breakpoint", http.StatusBadRequest)
+			return
+		}
+		b.ID = id
+		if err := intercept.ValidateBreakpoint(b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.UpdateBreakpoint(&b); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadBreakpoints()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b)
+
+	case http.MethodDelete:
+		if err := storage.DeleteBreakpoint(id); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadBreakpoints()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT and DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func reloadBreakpoints() {
+	if err := intercept.LoadBreakpoints(); err != nil {
+		log.Println("Error reloading breakpoints:", err)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/scanner"
+	"MITM_PROXY/pkg/storage"
+)
+
+// scanJobs serves GET /jobs and POST /jobs {"request_id": 1, "kind": "ssrf"}.
+func scanJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		jobs, err := storage.ListScanJobs()
+		if err != nil {
+			http.Error(w, "Failed to get jobs", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs)
+
+	case http.MethodPost:
+		var body struct {
+			RequestID int    `json:"request_id"`
+			Kind      string `json:"kind"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RequestID == 0 {
+			http.Error(w, "Expected {\"request_id\": N, \"kind\": \"...\"}", http.StatusBadRequest)
+			return
+		}
+		if body.Kind == "" {
+			body.Kind = "all"
+		}
+		if !scanner.ValidJobKind(body.Kind) {
+			http.Error(w, "Kind must be one of "+strings.Join(scanner.JobKinds, ", "), http.StatusBadRequest)
+			return
+		}
+		if req, err := storage.GetRequestByID(body.RequestID); err != nil || req == nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		job, err := scanner.SubmitJob(body.RequestID, body.Kind)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to queue job: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// scanJobByID serves GET /jobs/{id} and POST /jobs/{id}/cancel.
+func scanJobByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	var id int
+	if _, err := fmt.Sscanf(parts[0], "%d", &id); err != nil {
+		http.Error(w, "Bad job ID", http.StatusBadRequest)
+		return
+	}
+
+	if len(parts) > 1 && parts[1] == "cancel" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ok, err := scanner.CancelJob(id)
+		if err != nil {
+			http.Error(w, "Failed to cancel job", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "Job is not queued or running", http.StatusConflict)
+			return
+		}
+	} else if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, err := storage.GetScanJob(id)
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// scanLimits serves GET and PUT /jobs/limits {"concurrency": 2,
+// "per_second": 10}, the per-host caps on active-scan traffic. Missing or
+// zero fields keep their value.
+func scanLimits(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var l scanner.HostLimits
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil || l.Concurrency < 0 || l.PerSecond < 0 {
+			http.Error(w, "Expected {\"concurrency\": N, \"per_second\": N} with positive values", http.StatusBadRequest)
+			return
+		}
+		scanner.SetHostLimits(l.Concurrency, l.PerSecond)
+	default:
+		http.Error(w, "Only GET and PUT allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scanner.GetHostLimits())
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/rules"
+	"MITM_PROXY/pkg/storage"
+)
+
+// mapRules serves GET /maps and POST /maps.
+func mapRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListMapRules()
+		if err != nil {
+			http.Error(w, "Failed to get map rules", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		rule := storage.MapRule{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Bad map rule", http.StatusBadRequest)
+			return
+		}
+		if err := rules.ValidateMap(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.CreateMapRule(&rule); err != nil {
+			http.Error(w, "Failed to save map rule", http.StatusInternalServerError)
+			return
+		}
+		reloadRules()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// mapRuleByID serves GET, PUT and DELETE /maps/{id}.
+func mapRuleByID(w http.ResponseWriter, r *http.Request) {
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/maps/"), "%d", &id); err != nil {
+		http.Error(w, "Bad map rule ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := storage.GetMapRule(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodPut:
+		var rule storage.MapRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Bad map rule", http.StatusBadRequest)
+			return
+		}
+		rule.ID = id
+		if err := rules.ValidateMap(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.UpdateMapRule(&rule); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadRules()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodDelete:
+		if err := storage.DeleteMapRule(id); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadRules()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT and DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
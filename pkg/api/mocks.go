@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/mock"
+	"MITM_PROXY/pkg/storage"
+)
+
+// mocks serves GET /mocks and POST /mocks. With ?request_id= the mock is
+// seeded from that captured request and its response, and the optional
+// JSON body overrides individual fields.
+func mocks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListMocks()
+		if err != nil {
+			http.Error(w, "Failed to get mocks", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		m := &storage.Mock{Enabled: true}
+		if s := r.URL.Query().Get("request_id"); s != "" {
+			var requestID int
+			if _, err := fmt.Sscanf(s, "%d", &requestID); err != nil {
+				http.Error(w, "Bad request ID", http.StatusBadRequest)
+				return
+			}
+			seeded, err := mock.FromCapture(requestID)
+			if err != nil {
+				http.Error(w, "Request or response not found", http.StatusNotFound)
+				return
+			}
+			m = seeded
+		}
+		if err := json.NewDecoder(r.Body).Decode(m); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Bad mock", http.StatusBadRequest)
+			return
+		}
+		if err := mock.Validate(*m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.CreateMock(m); err != nil {
+			http.Error(w, "Failed to save mock", http.StatusInternalServerError)
+			return
+		}
+		reloadMocks()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(m)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// mockByID serves GET, PUT and DELETE /mocks/{id}.
+func mockByID(w http.ResponseWriter, r *http.Request) {
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/mocks/"), "%d", &id); err != nil {
+		http.Error(w, "Bad mock ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		m, err := storage.GetMock(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m)
+
+	case http.MethodPut:
+		var m storage.Mock
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			http.Error(w, "Bad mock", http.StatusBadRequest)
+			return
+		}
+		m.ID = id
+		if err := mock.Validate(m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.UpdateMock(&m); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadMocks()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m)
+
+	case http.MethodDelete:
+		if err := storage.DeleteMock(id); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadMocks()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT and DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func reloadMocks() {
+	if err := mock.Load(); err != nil {
+		log.Println("Error reloading mocks:", err)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/netsim"
+	"MITM_PROXY/pkg/storage"
+)
+
+// networkRules serves GET /network and POST /network.
+func networkRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListNetworkRules()
+		if err != nil {
+			http.Error(w, "Failed to get network rules", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		rule := storage.NetworkRule{Enabled: true, ErrorStatus: http.StatusServiceUnavailable}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Bad network rule", http.StatusBadRequest)
+			return
+		}
+		if err := netsim.Validate(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.CreateNetworkRule(&rule); err != nil {
+			http.Error(w, "Failed to save network rule", http.StatusInternalServerError)
+			return
+		}
+		reloadNetwork()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// networkRuleByID serves GET, PUT and DELETE /network/{id}.
+func networkRuleByID(w http.ResponseWriter, r *http.Request) {
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/network/"), "%d", &id); err != nil {
+		http.Error(w, "Bad network rule ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := storage.GetNetworkRule(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodPut:
+		var rule storage.NetworkRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Bad network rule", http.StatusBadRequest)
+			return
+		}
+		rule.ID = id
+		if err := netsim.Validate(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.UpdateNetworkRule(&rule); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadNetwork()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodDelete:
+		if err := storage.DeleteNetworkRule(id); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadNetwork()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT and DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func reloadNetwork() {
+	if err := netsim.Load(); err != nil {
+		log.Println("Error reloading network rules:", err)
+	}
+}
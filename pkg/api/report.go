@@ -0,0 +1,40 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/report"
+	"MITM_PROXY/pkg/storage"
+)
+
+// getReport serves GET /report/{format}?host=&severity=&status=
+func getReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := strings.Trim(strings.TrimPrefix(r.URL.Path, "/report/"), "/")
+	q := r.URL.Query()
+	filter := storage.FindingFilter{
+		Host:        q.Get("host"),
+		MinSeverity: q.Get("severity"),
+		Status:      q.Get("status"),
+	}
+	if filter.MinSeverity != "" && storage.SeverityRank(filter.MinSeverity) < 0 {
+		http.Error(w, "Unknown severity", http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := report.Generate(&buf, format, filter); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate report: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", report.ContentType(format))
+	w.Write(buf.Bytes())
+}
@@ -13,6 +13,37 @@ func StartWebAPI() {
 	mux.HandleFunc("/repeat/", repeatRequest)
 	mux.HandleFunc("/scan/", scanRequest)
 	mux.HandleFunc("/scan-xxe/{id}", scanXXE)
+	mux.HandleFunc("/scan-ssrf/{id}", scanSSRF)
+	mux.HandleFunc("/findings", listFindings)
+	mux.HandleFunc("/findings/", findingByID)
+	mux.HandleFunc("/jobs", scanJobs)
+	mux.HandleFunc("/jobs/", scanJobByID)
+	mux.HandleFunc("/jobs/limits", scanLimits)
+	mux.HandleFunc("/report/", getReport)
+	mux.HandleFunc("/intercept", interceptQueue)
+	mux.HandleFunc("/intercept/", interceptItem)
+	mux.HandleFunc("/rules", rewriteRules)
+	mux.HandleFunc("/rules/", rewriteRuleByID)
+	mux.HandleFunc("/maps", mapRules)
+	mux.HandleFunc("/maps/", mapRuleByID)
+	mux.HandleFunc("/mocks", mocks)
+	mux.HandleFunc("/mocks/", mockByID)
+	mux.HandleFunc("/scope", scopeRules)
+	mux.HandleFunc("/scope/", scopeRuleByID)
+	mux.HandleFunc("/upstream-tls", upstreamTLS)
+	mux.HandleFunc("/passthrough", passThroughHosts)
+	mux.HandleFunc("/passthrough/", passThroughHostByID)
+	mux.HandleFunc("/tunnels", listTunnels)
+	mux.HandleFunc("/tls-connections", listTLSConnections)
+	mux.HandleFunc("/tls-connections/", tlsConnectionByID)
+	mux.HandleFunc("/blocklist", blockRules)
+	mux.HandleFunc("/blocklist/", blockRuleByID)
+	mux.HandleFunc("/network", networkRules)
+	mux.HandleFunc("/network/", networkRuleByID)
+	mux.HandleFunc("/grpc-descriptors", grpcDescriptorSets)
+	mux.HandleFunc("/grpc-descriptors/", grpcDescriptorSetByID)
+	mux.HandleFunc("/scripts", scripts)
+	mux.HandleFunc("/scripts/", scriptByID)
 
 	log.Println("Web API listening on :8000")
 	if err := http.ListenAndServe(":8000", mux); err != nil {
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/rules"
+	"MITM_PROXY/pkg/storage"
+)
+
+// rewriteRules serves GET /rules and POST /rules.
+func rewriteRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListRewriteRules()
+		if err != nil {
+			http.Error(w, "Failed to get rules", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		rule := storage.RewriteRule{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Bad rule", http.StatusBadRequest)
+			return
+		}
+		if err := rules.Validate(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.CreateRewriteRule(&rule); err != nil {
+			http.Error(w, "Failed to save rule", http.StatusInternalServerError)
+			return
+		}
+		reloadRules()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// rewriteRuleByID serves GET, PUT and DELETE /rules/{id}.
+func rewriteRuleByID(w http.ResponseWriter, r *http.Request) {
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/rules/"), "%d", &id); err != nil {
+		http.Error(w, "Bad rule ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := storage.GetRewriteRule(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodPut:
+		var rule storage.RewriteRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Bad rule", http.StatusBadRequest)
+			return
+		}
+		rule.ID = id
+		if err := rules.Validate(rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.UpdateRewriteRule(&rule); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadRules()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rule)
+
+	case http.MethodDelete:
+		if err := storage.DeleteRewriteRule(id); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadRules()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT and DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func reloadRules() {
+	if err := rules.Load(); err != nil {
+		log.Println("Error reloading rewrite rules:", err)
+	}
+}
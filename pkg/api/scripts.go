@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/script"
+	"MITM_PROXY/pkg/storage"
+)
+
+type scriptInfo struct {
+	storage.Script
+	LastError string `json:"last_error,omitempty"`
+}
+
+// scripts serves GET /scripts and POST /scripts.
+func scripts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListScripts()
+		if err != nil {
+			http.Error(w, "Failed to get scripts", http.StatusInternalServerError)
+			return
+		}
+		errs := script.Errors()
+		infos := make([]scriptInfo, 0, len(list))
+		for _, s := range list {
+			infos = append(infos, scriptInfo{Script: s, LastError: errs[s.ID]})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(infos)
+
+	case http.MethodPost:
+		s := storage.Script{Enabled: true}
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "Bad script", http.StatusBadRequest)
+			return
+		}
+		if err := script.Validate(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.CreateScript(&s); err != nil {
+			http.Error(w, "Failed to save script", http.StatusInternalServerError)
+			return
+		}
+		reloadScripts()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// scriptByID serves GET, PUT and DELETE /scripts/{id}.
+func scriptByID(w http.ResponseWriter, r *http.Request) {
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/scripts/"), "%d", &id); err != nil {
+		http.Error(w, "Bad script ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s, err := storage.GetScript(id)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(scriptInfo{Script: *s, LastError: script.Errors()[id]})
+
+	case http.MethodPut:
+		var s storage.Script
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "Bad script", http.StatusBadRequest)
+			return
+		}
+		s.ID = id
+		if err := script.Validate(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := storage.UpdateScript(&s); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadScripts()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+
+	case http.MethodDelete:
+		if err := storage.DeleteScript(id); err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		reloadScripts()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET, PUT and DELETE allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func reloadScripts() {
+	if err := script.Load(); err != nil {
+		log.Println("Error reloading scripts:", err)
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/storage"
+	"MITM_PROXY/pkg/tlspass"
+)
+
+// passThroughHosts serves GET /passthrough and POST /passthrough with
+// body {"host": "*.example.com"}.
+func passThroughHosts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := storage.ListPassThroughHosts()
+		if err != nil {
+			http.Error(w, "Failed to get pass-through hosts", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		var body struct {
+			Host string `json:"host"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Host == "" {
+			http.Error(w, "Expected {\"host\": ...}", http.StatusBadRequest)
+			return
+		}
+		h, err := tlspass.Add(body.Host, tlspass.ReasonManual)
+		if err != nil {
+			http.Error(w, "Failed to save pass-through host", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(h)
+
+	default:
+		http.Error(w, "Only GET and POST allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// passThroughHostByID serves DELETE /passthrough/{id}.
+func passThroughHostByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Only DELETE allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/passthrough/"), "%d", &id); err != nil {
+		http.Error(w, "Bad pass-through host ID", http.StatusBadRequest)
+		return
+	}
+	if err := tlspass.Remove(id); err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listTunnels serves GET /tunnels?host=&limit=.
+func listTunnels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 100
+	if s := r.URL.Query().Get("limit"); s != "" {
+		if _, err := fmt.Sscanf(s, "%d", &limit); err != nil || limit <= 0 {
+			http.Error(w, "Bad limit", http.StatusBadRequest)
+			return
+		}
+	}
+	tunnels, err := storage.ListTunnels(r.URL.Query().Get("host"), limit)
+	if err != nil {
+		http.Error(w, "Failed to get tunnels", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tunnels)
+}
+
+// listTLSConnections serves GET /tls-connections?host=&limit=, where host
+// matches the SNI or the CONNECT target.
+func listTLSConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 100
+	if s := r.URL.Query().Get("limit"); s != "" {
+		if _, err := fmt.Sscanf(s, "%d", &limit); err != nil || limit <= 0 {
+			http.Error(w, "Bad limit", http.StatusBadRequest)
+			return
+		}
+	}
+	conns, err := storage.ListTLSConnections(r.URL.Query().Get("host"), limit)
+	if err != nil {
+		http.Error(w, "Failed to get TLS connections", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conns)
+}
+
+// tlsConnectionByID serves GET /tls-connections/{id}.
+func tlsConnectionByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var id int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/tls-connections/"), "%d", &id); err != nil {
+		http.Error(w, "Bad TLS connection ID", http.StatusBadRequest)
+		return
+	}
+	c, err := storage.GetTLSConnection(id)
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
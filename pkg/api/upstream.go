@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"MITM_PROXY/pkg/upstream"
+)
+
+// upstreamTLS serves GET and PUT /upstream-tls with the upstream trust
+// and ClientHello fingerprint configuration.
+func upstreamTLS(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var c upstream.Config
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			http.Error(w, "Bad config", http.StatusBadRequest)
+			return
+		}
+		if err := upstream.Validate(c); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := upstream.Save(c); err != nil {
+			http.Error(w, "Failed to save upstream TLS config", http.StatusInternalServerError)
+			return
+		}
+		if err := upstream.SetConfig(c); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Only GET and PUT allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(upstream.GetConfig())
+}
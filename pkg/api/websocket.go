@@ -0,0 +1,25 @@
+package api
+
+import "MITM_PROXY/pkg/storage"
+
+// websocketMessage shows text messages as text and binary ones base64
+// encoded.
+type websocketMessage struct {
+	storage.WebSocketMessage
+	Size int    `json:"size"`
+	Text string `json:"text,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+func websocketMessages(stored []storage.WebSocketMessage) []websocketMessage {
+	msgs := make([]websocketMessage, len(stored))
+	for i, m := range stored {
+		msgs[i] = websocketMessage{WebSocketMessage: m, Size: len(m.Data)}
+		if m.Type == "text" {
+			msgs[i].Text = string(m.Data)
+		} else {
+			msgs[i].Data = m.Data
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,245 @@
+// Package blocklist decides which hosts and URLs the proxy may contact.
+//
+// Rules are checked in ID order and the first match wins. Once any allow
+// rule is enabled the list turns into an allow list: traffic that no rule
+// matches is blocked with 403.
+//
+// CONNECT targets carry no path, so URL rules are left to the requests
+// inside intercepted tunnels. For the same reason a tunnel that no host or
+// CIDR rule allows is only let through when some URL rule may allow the
+// requests inside it.
+package blocklist
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sync"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+const (
+	KindHost = "host"
+	KindURL  = "url"
+	KindCIDR = "cidr"
+
+	ActionBlock = "block"
+	ActionReset = "reset"
+	ActionAllow = "allow"
+)
+
+// Verdict is the outcome of Check. Status is 0 when the connection should
+// be reset.
+type Verdict struct {
+	Blocked bool
+	RuleID  int // 0 when blocked by the allow list default
+	Status  int
+}
+
+// Counters reports how often each rule matched.
+type Counters struct {
+	Rules        map[int]int64 `json:"rules"`
+	NotAllowed   int64         `json:"not_allowed"` // blocked by the allow list default
+	TotalBlocked int64         `json:"total_blocked"`
+}
+
+type compiled struct {
+	storage.BlockRule
+	re  *regexp.Regexp
+	net *net.IPNet
+}
+
+var (
+	mu        sync.RWMutex
+	active    []compiled
+	allowList bool
+	urlAllow  bool // some URL rule allows
+
+	countMu  sync.Mutex
+	counters = Counters{Rules: map[int]int64{}}
+)
+
+func compile(r storage.BlockRule) (compiled, error) {
+	c := compiled{BlockRule: r}
+	switch r.Action {
+	case ActionBlock:
+		if r.Status < 100 || r.Status > 999 {
+			return c, fmt.Errorf("bad status %d", r.Status)
+		}
+	case ActionReset, ActionAllow:
+	default:
+		return c, fmt.Errorf("action must be %q, %q or %q", ActionBlock, ActionReset, ActionAllow)
+	}
+	switch r.Kind {
+	case KindHost:
+		if _, err := path.Match(r.Pattern, ""); err != nil || r.Pattern == "" {
+			return c, fmt.Errorf("bad host pattern %q", r.Pattern)
+		}
+	case KindURL:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return c, fmt.Errorf("bad url pattern: %w", err)
+		}
+		c.re = re
+	case KindCIDR:
+		_, n, err := net.ParseCIDR(r.Pattern)
+		if err != nil {
+			ip := net.ParseIP(r.Pattern)
+			if ip == nil {
+				return c, fmt.Errorf("bad CIDR %q", r.Pattern)
+			}
+			n = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+		}
+		c.net = n
+	default:
+		return c, fmt.Errorf("kind must be %q, %q or %q", KindHost, KindURL, KindCIDR)
+	}
+	return c, nil
+}
+
+// Validate checks that r can be compiled.
+func Validate(r storage.BlockRule) error {
+	_, err := compile(r)
+	return err
+}
+
+// Load replaces the active rules with the enabled ones from storage.
+func Load() error {
+	stored, err := storage.ListBlockRules()
+	if err != nil {
+		return err
+	}
+	activate(stored)
+	return nil
+}
+
+// activate compiles the enabled rules of stored and makes them active.
+func activate(stored []storage.BlockRule) {
+	var next []compiled
+	allow, allowURL := false, false
+	for _, r := range stored {
+		if !r.Enabled {
+			continue
+		}
+		c, err := compile(r)
+		if err != nil {
+			log.Printf("Skipping block rule %d: %v", r.ID, err)
+			continue
+		}
+		next = append(next, c)
+		allow = allow || r.Action == ActionAllow
+		allowURL = allowURL || r.Action == ActionAllow && r.Kind == KindURL
+	}
+
+	mu.Lock()
+	active, allowList, urlAllow = next, allow, allowURL
+	mu.Unlock()
+}
+
+// Check decides whether u may be contacted; tunnel is set for CONNECT
+// targets. Host names are resolved only when a CIDR rule needs it.
+func Check(u *url.URL, tunnel bool) Verdict {
+	mu.RLock()
+	rules, allow, allowURL := active, allowList, urlAllow
+	mu.RUnlock()
+
+	var ips []net.IP
+	resolved := false
+	for _, r := range rules {
+		var hit bool
+		switch r.Kind {
+		case KindHost:
+			hit = match.Host(r.Pattern, u.Host)
+		case KindURL:
+			hit = !tunnel && r.re.MatchString(u.String())
+		case KindCIDR:
+			if !resolved {
+				resolved = true
+				ips = resolve(u.Hostname())
+			}
+			for _, ip := range ips {
+				if r.net.Contains(ip) {
+					hit = true
+					break
+				}
+			}
+		}
+		if !hit {
+			continue
+		}
+
+		v := Verdict{RuleID: r.ID}
+		switch r.Action {
+		case ActionAllow:
+			count(v)
+			return v
+		case ActionBlock:
+			v.Blocked, v.Status = true, r.Status
+		case ActionReset:
+			v.Blocked = true
+		}
+		count(v)
+		return v
+	}
+
+	if allow && !(tunnel && allowURL) {
+		v := Verdict{Blocked: true, Status: http.StatusForbidden}
+		count(v)
+		return v
+	}
+	return Verdict{}
+}
+
+func resolve(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	return ips
+}
+
+func count(v Verdict) {
+	countMu.Lock()
+	defer countMu.Unlock()
+	if v.RuleID != 0 {
+		counters.Rules[v.RuleID]++
+	} else {
+		counters.NotAllowed++
+	}
+	if v.Blocked {
+		counters.TotalBlocked++
+	}
+}
+
+// GetCounters returns a snapshot of the match counters since start.
+func GetCounters() Counters {
+	countMu.Lock()
+	defer countMu.Unlock()
+	c := Counters{Rules: make(map[int]int64, len(counters.Rules)), NotAllowed: counters.NotAllowed, TotalBlocked: counters.TotalBlocked}
+	for id, n := range counters.Rules {
+		c.Rules[id] = n
+	}
+	return c
+}
+
+// ResetCounters sets all counters back to zero.
+func ResetCounters() {
+	countMu.Lock()
+	counters = Counters{Rules: map[int]int64{}}
+	countMu.Unlock()
+}
+
+// Block adapts Check to proxy.Options.Block.
+func Block(u *url.URL, tunnel bool) (status int, blocked bool) {
+	v := Check(u, tunnel)
+	return v.Status, v.Blocked
+}
@@ -0,0 +1,59 @@
+package blocklist
+
+import (
+	"net/url"
+	"testing"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// use activates rules as Load would, without storage.
+func use(t *testing.T, rules ...storage.BlockRule) {
+	t.Helper()
+	for i := range rules {
+		rules[i].ID, rules[i].Enabled = i+1, true
+	}
+	activate(rules)
+	t.Cleanup(func() { activate(nil) })
+}
+
+func TestCheck(t *testing.T) {
+	allowHost := storage.BlockRule{Kind: KindHost, Pattern: "*.example.com", Action: ActionAllow}
+	allowCIDR := storage.BlockRule{Kind: KindCIDR, Pattern: "10.0.0.0/8", Action: ActionAllow}
+	allowURL := storage.BlockRule{Kind: KindURL, Pattern: `^https://api\.test/v1/`, Action: ActionAllow}
+	blockURL := storage.BlockRule{Kind: KindURL, Pattern: `evil`, Action: ActionBlock, Status: 403}
+
+	tests := []struct {
+		name        string
+		rules       []storage.BlockRule
+		url         string
+		tunnel      bool
+		wantBlocked bool
+	}{
+		{"no rules", nil, "http://a.test/", false, false},
+		{"allowed host", []storage.BlockRule{allowHost}, "http://www.example.com/x", false, false},
+		{"not allowed", []storage.BlockRule{allowHost}, "http://other.test/x", false, true},
+		{"not allowed without path", []storage.BlockRule{allowHost}, "http://other.test", false, true},
+		{"allowed tunnel", []storage.BlockRule{allowHost}, "https://www.example.com:443", true, false},
+		{"tunnel not allowed", []storage.BlockRule{allowHost}, "https://other.test:443", true, true},
+		{"tunnel allowed by CIDR", []storage.BlockRule{allowCIDR}, "https://10.1.2.3:443", true, false},
+		{"tunnel not allowed by CIDR", []storage.BlockRule{allowCIDR}, "https://192.0.2.1:443", true, true},
+		{"tunnel left to URL rules", []storage.BlockRule{allowHost, allowURL}, "https://api.test:443", true, false},
+		{"request not allowed by URL", []storage.BlockRule{allowHost, allowURL}, "https://api.test/v2/", false, true},
+		{"request allowed by URL", []storage.BlockRule{allowHost, allowURL}, "https://api.test/v1/x", false, false},
+		{"URL block ignores tunnels", []storage.BlockRule{blockURL}, "https://evil.test:443", true, false},
+		{"URL block", []storage.BlockRule{blockURL}, "https://evil.test/", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			use(t, tt.rules...)
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := Check(u, tt.tunnel); v.Blocked != tt.wantBlocked {
+				t.Errorf("Check(%s, %v) = %+v, want blocked %v", tt.url, tt.tunnel, v, tt.wantBlocked)
+			}
+		})
+	}
+}
@@ -8,27 +8,57 @@ import (
 	"crypto/x509/pkix"
 	"fmt"
 	"math/big"
+	"net"
+	"strings"
 	"time"
 )
 
+// maxValidity keeps forged certificates within what browsers accept for
+// publicly trusted leaves.
+const maxValidity = 397 * 24 * time.Hour
+
 func BuildCertificate(host string, caCert *x509.Certificate, caKey *rsa.PrivateKey) (tls.Certificate, error) {
+	return BuildCertificateFrom(host, nil, caCert, caKey)
+}
+
+// BuildCertificateFrom forges a leaf for host signed by the CA. When the
+// real upstream certificate is known its subject, SANs (DNS names,
+// wildcards and IPs) and validity are mirrored, so clients checking those
+// see what they would without the proxy.
+func BuildCertificateFrom(host string, upstream *x509.Certificate, caCert *x509.Certificate, caKey *rsa.PrivateKey) (tls.Certificate, error) {
 	serialNumber, err := rand.Int(rand.Reader, big.NewInt(0).SetUint64(^uint64(0)>>1))
 	if err != nil {
 		return tls.Certificate{}, err
 	}
 
+	now := time.Now()
 	template := x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			CommonName: host,
 		},
-		NotBefore:             time.Now().Add(-time.Hour),
-		NotAfter:              time.Now().AddDate(10, 0, 0),
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(maxValidity),
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 		IsCA:                  false,
-		DNSNames:              []string{host},
+	}
+	if upstream != nil {
+		template.Subject = upstream.Subject
+		template.Subject.ExtraNames = nil
+		template.DNSNames = append([]string(nil), upstream.DNSNames...)
+		template.IPAddresses = append([]net.IP(nil), upstream.IPAddresses...)
+		template.NotBefore, template.NotAfter = validity(upstream, now)
+	}
+	addHost(&template, host)
+
+	// A leaf outliving its CA is rejected by most clients
+	if template.NotAfter.After(caCert.NotAfter) {
+		template.NotAfter = caCert.NotAfter
+	}
+	if template.NotBefore.Before(caCert.NotBefore) {
+		template.NotBefore = caCert.NotBefore
 	}
 
 	priv, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -47,3 +77,57 @@ func BuildCertificate(host string, caCert *x509.Certificate, caKey *rsa.PrivateK
 	}
 	return cert, nil
 }
+
+// validity mirrors the validity window of upstream as far as it is sane:
+// it must include now and span at most maxValidity.
+func validity(upstream *x509.Certificate, now time.Time) (notBefore, notAfter time.Time) {
+	notBefore, notAfter = upstream.NotBefore, upstream.NotAfter
+	if notBefore.After(now) || notBefore.Before(now.Add(-maxValidity)) {
+		notBefore = now.Add(-time.Hour)
+	}
+	if !notAfter.After(now) {
+		notBefore = now.Add(-time.Hour)
+		notAfter = notBefore.Add(maxValidity)
+	}
+	if notAfter.After(notBefore.Add(maxValidity)) {
+		notAfter = notBefore.Add(maxValidity)
+	}
+	return notBefore, notAfter
+}
+
+// addHost makes sure the certificate covers host, as an IP SAN for IP
+// addresses and as a DNS SAN otherwise.
+func addHost(template *x509.Certificate, host string) {
+	if host == "" {
+		return
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		for _, have := range template.IPAddresses {
+			if have.Equal(ip) {
+				return
+			}
+		}
+		template.IPAddresses = append(template.IPAddresses, ip)
+		return
+	}
+	for _, name := range template.DNSNames {
+		if matchesName(name, host) {
+			return
+		}
+	}
+	template.DNSNames = append(template.DNSNames, host)
+}
+
+// matchesName reports whether the SAN name (possibly "*.example.com")
+// covers host.
+func matchesName(name, host string) bool {
+	name, host = strings.ToLower(name), strings.ToLower(host)
+	if name == host {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(name, "*."); ok {
+		label, rest, found := strings.Cut(host, ".")
+		return found && label != "" && rest == suffix
+	}
+	return false
+}
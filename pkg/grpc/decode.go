@@ -0,0 +1,214 @@
+package grpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// maxDepth bounds how deep schemaless decoding looks for nested messages.
+const maxDepth = 16
+
+var errNoSchema = errors.New("no descriptor for method")
+
+var (
+	mu    sync.RWMutex
+	files []*protoregistry.Files // one per descriptor set
+)
+
+func compile(data []byte) (*protoregistry.Files, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("not a FileDescriptorSet: %w", err)
+	}
+	if len(set.File) == 0 {
+		return nil, errors.New("empty FileDescriptorSet")
+	}
+	return protodesc.NewFiles(&set)
+}
+
+// Validate checks that data is a usable FileDescriptorSet. It must include
+// its imports.
+func Validate(data []byte) error {
+	_, err := compile(data)
+	return err
+}
+
+// Load replaces the descriptor sets with the ones in storage.
+func Load() error {
+	sets, err := storage.ListGRPCDescriptorSets()
+	if err != nil {
+		return err
+	}
+	var compiled []*protoregistry.Files
+	for _, s := range sets {
+		f, err := compile(s.Data)
+		if err != nil {
+			log.Printf("Skipping gRPC descriptor set %d: %v", s.ID, err)
+			continue
+		}
+		compiled = append(compiled, f)
+	}
+
+	mu.Lock()
+	files = compiled
+	mu.Unlock()
+	return nil
+}
+
+// Add stores a descriptor set after validating it.
+func Add(name string, data []byte) (*storage.GRPCDescriptorSet, error) {
+	if err := Validate(data); err != nil {
+		return nil, err
+	}
+	s := &storage.GRPCDescriptorSet{Name: name, Data: data}
+	if err := storage.CreateGRPCDescriptorSet(s); err != nil {
+		return nil, err
+	}
+	return s, Load()
+}
+
+// Remove deletes descriptor set #id.
+func Remove(id int) error {
+	if err := storage.DeleteGRPCDescriptorSet(id); err != nil {
+		return err
+	}
+	return Load()
+}
+
+// methodDescriptor finds /package.Service/Method in the descriptor sets.
+func methodDescriptor(method string) protoreflect.MethodDescriptor {
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok {
+		return nil
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, f := range files {
+		d, err := f.FindDescriptorByName(protoreflect.FullName(service))
+		if err != nil {
+			continue
+		}
+		if sd, ok := d.(protoreflect.ServiceDescriptor); ok {
+			if md := sd.Methods().ByName(protoreflect.Name(name)); md != nil {
+				return md
+			}
+		}
+	}
+	return nil
+}
+
+func decodeWithSchema(method, direction string, data []byte) (json.RawMessage, error) {
+	md := methodDescriptor(method)
+	if md == nil {
+		return nil, errNoSchema
+	}
+	typ := md.Input()
+	if direction == DirectionResponse {
+		typ = md.Output()
+	}
+	msg := dynamicpb.NewMessage(typ)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(msg)
+}
+
+// decodeSchemaless turns a protobuf message into a map keyed by field
+// number. Length-delimited fields become strings when they are printable
+// text, nested messages when they parse as one, and bytes otherwise.
+// Repeated fields become arrays.
+func decodeSchemaless(b []byte, depth int) (map[string]any, bool) {
+	fields := map[string]any{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, false
+		}
+		b = b[n:]
+
+		var v any
+		switch typ {
+		case protowire.VarintType:
+			var x uint64
+			x, n = protowire.ConsumeVarint(b)
+			v = x
+		case protowire.Fixed32Type:
+			var x uint32
+			x, n = protowire.ConsumeFixed32(b)
+			v = x
+		case protowire.Fixed64Type:
+			var x uint64
+			x, n = protowire.ConsumeFixed64(b)
+			v = x
+		case protowire.BytesType:
+			var x []byte
+			x, n = protowire.ConsumeBytes(b)
+			v = decodeBytes(x, depth)
+		case protowire.StartGroupType:
+			var x []byte
+			x, n = protowire.ConsumeGroup(num, b)
+			if n >= 0 {
+				v, _ = decodeSchemaless(x, depth+1)
+			}
+		default:
+			return nil, false
+		}
+		if n < 0 {
+			return nil, false
+		}
+		b = b[n:]
+
+		key := strconv.Itoa(int(num))
+		switch prev := fields[key].(type) {
+		case nil:
+			fields[key] = v
+		case []any:
+			fields[key] = append(prev, v)
+		default:
+			fields[key] = []any{prev, v}
+		}
+	}
+	return fields, true
+}
+
+func decodeBytes(b []byte, depth int) any {
+	if printable(b) {
+		return string(b)
+	}
+	if depth < maxDepth {
+		if m, ok := decodeSchemaless(b, depth+1); ok && len(m) > 0 {
+			return m
+		}
+	}
+	return b
+}
+
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,221 @@
+// Package grpc records the messages of gRPC calls seen by the proxy and
+// decodes them to JSON.
+//
+// Request and response bodies are split into their length-prefixed
+// messages, which are stored one per row. Decoding happens when messages
+// are read back, so descriptor sets uploaded later apply to calls
+// captured earlier. Messages of methods found in a descriptor set are
+// decoded with their schema, others schemalessly with field numbers as
+// keys.
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+const (
+	DirectionRequest  = "request"
+	DirectionResponse = "response"
+)
+
+// MaxMessageSize bounds a single message, compressed or not. Larger ones
+// end the recording of their direction.
+const MaxMessageSize = 16 << 20
+
+// Frame is one length-prefixed message.
+type Frame struct {
+	Compressed bool
+	Data       []byte
+}
+
+// IsGRPC reports whether h is the header of a gRPC request or response.
+// gRPC-Web, which frames trailers differently, is not handled.
+func IsGRPC(h http.Header) bool {
+	ct := strings.ToLower(h.Get("Content-Type"))
+	return ct == "application/grpc" || strings.HasPrefix(ct, "application/grpc+") || strings.HasPrefix(ct, "application/grpc;")
+}
+
+// Split cuts body into frames, decompressing those flagged as compressed
+// when encoding is gzip. A truncated last frame is an error; the frames
+// before it are still returned.
+func Split(body []byte, encoding string) ([]Frame, error) {
+	s := Splitter{Encoding: encoding}
+	frames, err := s.Feed(body)
+	if err == nil && len(s.buf) > 0 {
+		err = errors.New("grpc: truncated frame")
+	}
+	return frames, err
+}
+
+// Splitter cuts a body into frames as it arrives, like Split.
+type Splitter struct {
+	Encoding string // grpc-encoding of the body
+
+	buf []byte
+	err error
+}
+
+// Feed adds the next piece of the body and returns the frames it
+// completed. After a frame larger than MaxMessageSize every call returns
+// the same error.
+func (s *Splitter) Feed(b []byte) ([]Frame, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.buf = append(s.buf, b...)
+
+	var frames []Frame
+	off := 0
+	for len(s.buf)-off >= 5 {
+		n := binary.BigEndian.Uint32(s.buf[off+1 : off+5])
+		if n > MaxMessageSize {
+			s.err = fmt.Errorf("grpc: %d byte message exceeds limit", n)
+			s.buf = nil
+			return frames, s.err
+		}
+		if uint64(len(s.buf)-off-5) < uint64(n) {
+			break
+		}
+		data := make([]byte, n)
+		copy(data, s.buf[off+5:])
+		f := Frame{Compressed: s.buf[off]&1 == 1, Data: data}
+		if f.Compressed && s.Encoding == "gzip" {
+			if data, err := gunzip(f.Data); err == nil {
+				f.Data, f.Compressed = data, false
+			}
+		}
+		frames = append(frames, f)
+		off += 5 + int(n)
+	}
+	s.buf = append(s.buf[:0], s.buf[off:]...)
+	return frames, nil
+}
+
+// Pending reports whether a frame was started but not completed.
+func (s *Splitter) Pending() bool {
+	return len(s.buf) > 0
+}
+
+func gunzip(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, MaxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxMessageSize {
+		return nil, errors.New("grpc: decompressed message exceeds limit")
+	}
+	return data, nil
+}
+
+// Recorder stores the messages of one direction of a gRPC call captured
+// as request #requestID, as the body is forwarded.
+type Recorder struct {
+	requestID int
+	method    string
+	direction string
+	splitter  Splitter
+	seq       int
+	failed    bool
+}
+
+// NewRecorder records the direction of the call to method whose header
+// is h.
+func NewRecorder(requestID int, method, direction string, h http.Header) *Recorder {
+	return &Recorder{
+		requestID: requestID,
+		method:    method,
+		direction: direction,
+		splitter:  Splitter{Encoding: h.Get("Grpc-Encoding")},
+	}
+}
+
+// Write stores the messages completed by the next piece of the body. It
+// never fails so that recording problems don't affect the call.
+func (r *Recorder) Write(b []byte) (int, error) {
+	frames, err := r.splitter.Feed(b)
+	for _, f := range frames {
+		m := &storage.GRPCMessage{
+			RequestID:  r.requestID,
+			Method:     r.method,
+			Direction:  r.direction,
+			Seq:        r.seq,
+			Compressed: f.Compressed,
+			Data:       f.Data,
+		}
+		r.seq++
+		if err := storage.SaveGRPCMessage(m); err != nil {
+			log.Printf("Error saving gRPC message of #%d: %v", r.requestID, err)
+		}
+	}
+	if err != nil && !r.failed {
+		r.failed = true
+		log.Printf("Error splitting gRPC %s of #%d: %v", r.direction, r.requestID, err)
+	}
+	return len(b), nil
+}
+
+// Close logs a message cut short by the end of the body.
+func (r *Recorder) Close() error {
+	if r.splitter.Pending() {
+		log.Printf("Error splitting gRPC %s of #%d: truncated frame", r.direction, r.requestID)
+	}
+	return nil
+}
+
+// Message is a stored message with its JSON decoding.
+type Message struct {
+	storage.GRPCMessage
+	Size    int    `json:"size"`
+	Decoder string `json:"decoder"` // schema, schemaless or none
+	JSON    any    `json:"json"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Messages returns the decoded messages of request #requestID.
+func Messages(requestID int) ([]Message, error) {
+	stored, err := storage.ListGRPCMessages(requestID)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]Message, len(stored))
+	for i, m := range stored {
+		msgs[i] = decode(m)
+	}
+	return msgs, nil
+}
+
+func decode(m storage.GRPCMessage) Message {
+	out := Message{GRPCMessage: m, Size: len(m.Data), Decoder: "none"}
+	if m.Compressed {
+		out.Error = "compressed with an unsupported grpc-encoding"
+		out.JSON = m.Data
+		return out
+	}
+	if v, err := decodeWithSchema(m.Method, m.Direction, m.Data); err == nil {
+		out.Decoder, out.JSON = "schema", v
+		return out
+	} else if !errors.Is(err, errNoSchema) {
+		out.Error = fmt.Sprintf("schema: %v", err)
+	}
+	if v, ok := decodeSchemaless(m.Data, 0); ok {
+		out.Decoder, out.JSON = "schemaless", v
+		return out
+	}
+	out.JSON = m.Data
+	return out
+}
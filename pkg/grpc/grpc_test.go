@@ -0,0 +1,107 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func frame(flag byte, data []byte) []byte {
+	b := []byte{flag, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(len(data)))
+	return append(b, data...)
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSplit(t *testing.T) {
+	hello := []byte("\x0a\x05hello")
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		want     []Frame
+		wantErr  bool
+	}{
+		{"empty", nil, "", nil, false},
+		{"one", frame(0, hello), "", []Frame{{Data: hello}}, false},
+		{"empty message", frame(0, nil), "", []Frame{{Data: []byte{}}}, false},
+		{"two", append(frame(0, hello), frame(0, []byte{8, 1})...), "", []Frame{{Data: hello}, {Data: []byte{8, 1}}}, false},
+		{"gzip", frame(1, gzipped(t, hello)), "gzip", []Frame{{Data: hello}}, false},
+		{"unknown encoding", frame(1, []byte("zzz")), "snappy", []Frame{{Compressed: true, Data: []byte("zzz")}}, false},
+		{"truncated header", append(frame(0, hello), 0, 0), "", []Frame{{Data: hello}}, true},
+		{"truncated message", frame(0, hello)[:8], "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(tt.body, tt.encoding)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frames = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSplitterPieces feeds a body one byte at a time.
+func TestSplitterPieces(t *testing.T) {
+	body := append(frame(0, []byte("first")), frame(0, []byte("second"))...)
+	var s Splitter
+	var got []string
+	for i := range body {
+		frames, err := s.Feed(body[i : i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range frames {
+			got = append(got, string(f.Data))
+		}
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if s.Pending() {
+		t.Error("Pending after the last frame")
+	}
+}
+
+func TestSplitterLimit(t *testing.T) {
+	hdr := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(hdr[1:], MaxMessageSize+1)
+	var s Splitter
+	if _, err := s.Feed(hdr); err == nil {
+		t.Fatal("oversized message accepted")
+	}
+	if _, err := s.Feed(frame(0, []byte("next"))); err == nil {
+		t.Error("Splitter resumed after an oversized message")
+	}
+}
+
+// TestGunzipLimit checks that a small gzip bomb is not inflated past the
+// message limit.
+func TestGunzipLimit(t *testing.T) {
+	bomb := gzipped(t, make([]byte, MaxMessageSize+1))
+	if _, err := gunzip(bomb); err == nil {
+		t.Error("gunzip inflated past MaxMessageSize")
+	}
+	frames, err := Split(frame(1, bomb), "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 1 || !frames[0].Compressed {
+		t.Error("oversized message should be kept compressed")
+	}
+}
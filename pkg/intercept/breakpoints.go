@@ -0,0 +1,161 @@
+package intercept
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+type compiledBreakpoint struct {
+	storage.Breakpoint
+	scope match.Scope
+	body  *regexp.Regexp
+}
+
+// Enabled breakpoints ordered by ID. The slice is replaced, never
+// modified, so readers can use it after unlocking.
+var (
+	bpMu        sync.RWMutex
+	breakpoints []compiledBreakpoint
+)
+
+func compileBreakpoint(b storage.Breakpoint) (compiledBreakpoint, error) {
+	c := compiledBreakpoint{Breakpoint: b}
+	scope, err := match.NewScope(b.Host, b.Path, b.Method)
+	if err != nil {
+		return c, fmt.Errorf("intercept: %w", err)
+	}
+	c.scope = scope
+	if b.MaxStatus != 0 && b.MaxStatus < b.MinStatus {
+		return c, fmt.Errorf("intercept: max_status is below min_status")
+	}
+	if b.JSONValue != "" && b.JSONField == "" {
+		return c, fmt.Errorf("intercept: json_value needs json_field")
+	}
+	if b.Body != "" {
+		re, err := regexp.Compile(b.Body)
+		if err != nil {
+			return c, fmt.Errorf("intercept: bad body pattern: %w", err)
+		}
+		c.body = re
+	}
+	return c, nil
+}
+
+// ValidateBreakpoint checks that b can be compiled.
+func ValidateBreakpoint(b storage.Breakpoint) error {
+	_, err := compileBreakpoint(b)
+	return err
+}
+
+// LoadBreakpoints replaces the active breakpoints with the enabled ones
+// from storage.
+func LoadBreakpoints() error {
+	stored, err := storage.ListBreakpoints()
+	if err != nil {
+		return err
+	}
+	var next []compiledBreakpoint
+	for _, b := range stored {
+		if !b.Enabled {
+			continue
+		}
+		c, err := compileBreakpoint(b)
+		if err != nil {
+			log.Printf("Skipping breakpoint %d: %v", b.ID, err)
+			continue
+		}
+		next = append(next, c)
+	}
+
+	bpMu.Lock()
+	breakpoints = next
+	bpMu.Unlock()
+	return nil
+}
+
+// hitBreakpoint returns the ID of the first enabled breakpoint matching
+// the response, or 0.
+func hitBreakpoint(req *http.Request, resp *http.Response, body []byte) int {
+	bpMu.RLock()
+	active := breakpoints
+	bpMu.RUnlock()
+
+	var decoded []byte
+	var doc any
+	parsed := false
+	for _, b := range active {
+		if !b.scope.Matches(req) {
+			continue
+		}
+		if b.MinStatus != 0 && resp.StatusCode < b.MinStatus {
+			continue
+		}
+		if b.MaxStatus != 0 && resp.StatusCode > b.MaxStatus {
+			continue
+		}
+		if b.body == nil && b.JSONField == "" {
+			return b.ID
+		}
+		if decoded == nil {
+			decoded = storage.DecodeBody(resp.Header, body)
+		}
+		if b.body != nil && !b.body.Match(decoded) {
+			continue
+		}
+		if b.JSONField != "" {
+			if !parsed {
+				parsed = true
+				if json.Unmarshal(decoded, &doc) != nil {
+					doc = nil
+				}
+			}
+			v, ok := lookupJSON(doc, b.JSONField)
+			if !ok || (b.JSONValue != "" && jsonString(v) != b.JSONValue) {
+				continue
+			}
+		}
+		return b.ID
+	}
+	return 0
+}
+
+// lookupJSON follows a dotted path through objects and arrays.
+func lookupJSON(doc any, path string) (any, bool) {
+	cur := doc
+	for _, key := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			cur = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func jsonString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
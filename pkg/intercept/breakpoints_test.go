@@ -0,0 +1,58 @@
+package intercept
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+func setBreakpoints(t *testing.T, list ...storage.Breakpoint) {
+	t.Helper()
+	var next []compiledBreakpoint
+	for _, b := range list {
+		c, err := compileBreakpoint(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next = append(next, c)
+	}
+	bpMu.Lock()
+	breakpoints = next
+	bpMu.Unlock()
+	t.Cleanup(func() { breakpoints = nil })
+}
+
+func TestHitBreakpoint(t *testing.T) {
+	setBreakpoints(t,
+		storage.Breakpoint{ID: 1, Host: "api.example.com", MinStatus: 500},
+		storage.Breakpoint{ID: 2, JSONField: "error.code", JSONValue: "42"},
+		storage.Breakpoint{ID: 3, Path: "^/admin", Body: "secret"},
+	)
+
+	// Breakpoints are evaluated without the lock guarding the queue
+	mu.Lock()
+	defer mu.Unlock()
+
+	tests := []struct {
+		url    string
+		status int
+		body   string
+		want   int
+	}{
+		{"https://api.example.com/x", 503, "", 1},
+		{"https://api.example.com/x", 200, "", 0},
+		{"https://other.example.com/x", 400, `{"error":{"code":42}}`, 2},
+		{"https://other.example.com/x", 400, `{"error":{"code":7}}`, 0},
+		{"https://other.example.com/admin/keys", 200, "the secret key", 3},
+		{"https://other.example.com/keys", 200, "the secret key", 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if got := hitBreakpoint(req, resp, []byte(tt.body)); got != tt.want {
+			t.Errorf("%s %d %q: breakpoint %d, want %d", tt.url, tt.status, tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package intercept
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+const (
+	ActionForward = "forward"
+	ActionDrop    = "drop"
+)
+
+// Config selects which traffic is held for manual review.
+type Config struct {
+	Requests       bool   `json:"requests"`
+	Responses      bool   `json:"responses"`
+	WebSockets     bool   `json:"websockets"`
+	Host           string `json:"host"`   // regexp, empty matches all
+	Method         string `json:"method"` // exact, empty matches all
+	Path           string `json:"path"`   // regexp, empty matches all
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	TimeoutAction  string `json:"timeout_action"` // forward or drop
+}
+
+// Item is a request, response or WebSocket message waiting for a decision.
+type Item struct {
+	ID        int         `json:"id"`
+	Kind      string      `json:"kind"` // request, response or websocket
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Status    int         `json:"status,omitempty"`
+	Header    http.Header `json:"header"`
+	Body      string      `json:"body"`
+	CreatedAt time.Time   `json:"created_at"`
+	Deadline  time.Time   `json:"deadline"`
+
+	// Breakpoint is the ID of the breakpoint that held a response, if any.
+	Breakpoint int `json:"breakpoint,omitempty"`
+
+	// Direction of a WebSocket message: client (to server) or server (to
+	// client). Binary messages have their Body base64 encoded, and edits
+	// must be too.
+	Direction string `json:"direction,omitempty"`
+	Binary    bool   `json:"binary,omitempty"`
+
+	decision chan Decision
+}
+
+// Decision tells a held item how to continue. Zero fields keep the
+// original value.
+type Decision struct {
+	Action string      `json:"action"`
+	Method string      `json:"method,omitempty"`
+	URL    string      `json:"url,omitempty"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   *string     `json:"body,omitempty"`
+}
+
+var ErrNotFound = errors.New("intercept: no such item")
+
+var (
+	mu      sync.Mutex
+	config  = Config{TimeoutSeconds: 60, TimeoutAction: ActionForward}
+	hostRe  *regexp.Regexp
+	pathRe  *regexp.Regexp
+	pending = map[int]*Item{}
+	nextID  = 1
+)
+
+func GetConfig() Config {
+	mu.Lock()
+	defer mu.Unlock()
+	return config
+}
+
+// SetConfig replaces the intercept configuration. Turning interception
+// off forwards everything that is still held, except for responses held
+// by a breakpoint.
+func SetConfig(c Config) error {
+	if c.TimeoutSeconds <= 0 {
+		c.TimeoutSeconds = 60
+	}
+	if c.TimeoutAction == "" {
+		c.TimeoutAction = ActionForward
+	}
+	if c.TimeoutAction != ActionForward && c.TimeoutAction != ActionDrop {
+		return fmt.Errorf("intercept: timeout_action must be %q or %q", ActionForward, ActionDrop)
+	}
+	h, err := compileOptional(c.Host)
+	if err != nil {
+		return fmt.Errorf("intercept: bad host pattern: %w", err)
+	}
+	p, err := compileOptional(c.Path)
+	if err != nil {
+		return fmt.Errorf("intercept: bad path pattern: %w", err)
+	}
+
+	mu.Lock()
+	config, hostRe, pathRe = c, h, p
+	var release []*Item
+	for _, item := range pending {
+		if (item.Kind == "request" && !c.Requests) || (item.Kind == "response" && !c.Responses && item.Breakpoint == 0) ||
+			(item.Kind == "websocket" && !c.WebSockets) {
+			release = append(release, item)
+		}
+	}
+	mu.Unlock()
+
+	for _, item := range release {
+		Decide(item.ID, Decision{Action: ActionForward})
+	}
+	return nil
+}
+
+func compileOptional(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+func matches(req *http.Request) bool {
+	if config.Method != "" && !strings.EqualFold(config.Method, req.Method) {
+		return false
+	}
+	if hostRe != nil && !hostRe.MatchString(req.URL.Host) {
+		return false
+	}
+	if pathRe != nil && !pathRe.MatchString(req.URL.Path) {
+		return false
+	}
+	return true
+}
+
+// Pending lists held items, oldest first.
+func Pending() []Item {
+	mu.Lock()
+	defer mu.Unlock()
+	items := make([]Item, 0, len(pending))
+	for _, item := range pending {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+// Decide releases held item #id.
+func Decide(id int, d Decision) error {
+	if d.Action == "" {
+		d.Action = ActionForward
+	}
+	if d.Action != ActionForward && d.Action != ActionDrop {
+		return fmt.Errorf("intercept: action must be %q or %q", ActionForward, ActionDrop)
+	}
+	mu.Lock()
+	item, ok := pending[id]
+	if ok && item.Binary && d.Body != nil {
+		if _, err := base64.StdEncoding.DecodeString(*d.Body); err != nil {
+			mu.Unlock()
+			return fmt.Errorf("intercept: binary message body must be base64: %w", err)
+		}
+	}
+	if ok {
+		delete(pending, id)
+	}
+	mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	item.decision <- d
+	return nil
+}
+
+// hold queues item and waits for a decision or the configured timeout.
+func hold(item *Item) Decision {
+	mu.Lock()
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	timeoutAction := config.TimeoutAction
+	item.ID = nextID
+	nextID++
+	item.CreatedAt = time.Now()
+	item.Deadline = item.CreatedAt.Add(timeout)
+	item.decision = make(chan Decision, 1)
+	pending[item.ID] = item
+	mu.Unlock()
+
+	select {
+	case d := <-item.decision:
+		return d
+	case <-time.After(timeout):
+		mu.Lock()
+		delete(pending, item.ID)
+		mu.Unlock()
+		// A decision may have raced with the timeout.
+		select {
+		case d := <-item.decision:
+			return d
+		default:
+		}
+		return Decision{Action: timeoutAction}
+	}
+}
+
+// Request holds req if it matches the configuration and applies the
+// decision. It returns the (possibly edited) request and body and reports
+// whether the request should be dropped. Callers fix up framing headers
+// for the new body.
+func Request(req *http.Request, body []byte) (*http.Request, []byte, bool) {
+	mu.Lock()
+	active := config.Requests && matches(req)
+	mu.Unlock()
+	if !active {
+		return req, body, false
+	}
+
+	d := hold(&Item{
+		Kind:   "request",
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   string(body),
+	})
+	if d.Action == ActionDrop {
+		return req, body, true
+	}
+
+	if d.Method != "" {
+		req.Method = d.Method
+	}
+	if d.URL != "" {
+		if u, err := url.Parse(d.URL); err == nil && u.Host != "" {
+			req.URL = u
+			req.Host = u.Host
+		}
+	}
+	if d.Header != nil {
+		req.Header = d.Header
+	}
+	if d.Body != nil {
+		body = []byte(*d.Body)
+	}
+	return req, body, false
+}
+
+// Response holds resp if it matches the configuration or a breakpoint and
+// applies the decision, like Request. Held bodies are decoded so they can
+// be edited.
+func Response(req *http.Request, resp *http.Response, body []byte) (*http.Response, []byte, bool) {
+	mu.Lock()
+	active := config.Responses && matches(req)
+	mu.Unlock()
+	breakpoint := 0
+	if !active {
+		breakpoint = hitBreakpoint(req, resp, body)
+	}
+	if !active && breakpoint == 0 {
+		return resp, body, false
+	}
+
+	body = storage.DecodeResponseBody(resp, body)
+
+	d := hold(&Item{
+		Kind:       "response",
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Status:     resp.StatusCode,
+		Breakpoint: breakpoint,
+		Header:     resp.Header.Clone(),
+		Body:       string(body),
+	})
+	if d.Action == ActionDrop {
+		return resp, body, true
+	}
+
+	if d.Status != 0 {
+		resp.StatusCode = d.Status
+		resp.Status = fmt.Sprintf("%d %s", d.Status, http.StatusText(d.Status))
+	}
+	if d.Header != nil {
+		resp.Header = d.Header
+	}
+	if d.Body != nil {
+		body = []byte(*d.Body)
+	}
+	return resp, body, false
+}
+
+// WebSocketMessage holds a message relayed on the WebSocket connection
+// opened by req if the configuration asks for it, and applies the
+// decision. It returns the (possibly edited) message and reports whether
+// it should be dropped.
+func WebSocketMessage(req *http.Request, fromClient, binary bool, data []byte) ([]byte, bool) {
+	mu.Lock()
+	active := config.WebSockets && matches(req)
+	mu.Unlock()
+	if !active {
+		return data, false
+	}
+
+	item := &Item{
+		Kind:      "websocket",
+		Method:    req.Method,
+		URL:       req.URL.String(),
+		Direction: "server",
+		Binary:    binary,
+		Body:      string(data),
+	}
+	if fromClient {
+		item.Direction = "client"
+	}
+	if binary {
+		item.Body = base64.StdEncoding.EncodeToString(data)
+	}
+	d := hold(item)
+	if d.Action == ActionDrop {
+		return data, true
+	}
+
+	if d.Body != nil {
+		if !binary {
+			return []byte(*d.Body), false
+		}
+		// Checked by Decide
+		if b, err := base64.StdEncoding.DecodeString(*d.Body); err == nil {
+			return b, false
+		}
+	}
+	return data, false
+}
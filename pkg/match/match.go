@@ -0,0 +1,69 @@
+// Package match implements the host/path/method scoping shared by the
+// traffic rules of the proxy.
+package match
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Host reports whether host (with or without port) matches the glob
+// pattern, e.g. "*.example.com". A pattern with a port, e.g.
+// "localhost:3000", only matches hosts on that port. An empty pattern
+// matches every host.
+func Host(pattern, host string) bool {
+	if pattern == "" {
+		return true
+	}
+	h, port, err := net.SplitHostPort(host)
+	if err == nil {
+		host = h
+	}
+	if p, pp, err := net.SplitHostPort(pattern); err == nil {
+		if ok, _ := path.Match(pp, port); !ok {
+			return false
+		}
+		pattern = p
+	}
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(host))
+	return ok
+}
+
+// Scope restricts a rule to a host glob, a path regexp and a method.
+type Scope struct {
+	Host   string
+	Path   *regexp.Regexp
+	Method string
+}
+
+func NewScope(host, pathPattern, method string) (Scope, error) {
+	if _, err := path.Match(host, ""); err != nil {
+		return Scope{}, fmt.Errorf("bad host pattern %q: %w", host, err)
+	}
+	s := Scope{Host: host, Method: method}
+	if pathPattern != "" {
+		re, err := regexp.Compile(pathPattern)
+		if err != nil {
+			return Scope{}, fmt.Errorf("bad path pattern %q: %w", pathPattern, err)
+		}
+		s.Path = re
+	}
+	return s, nil
+}
+
+func (s Scope) Matches(req *http.Request) bool {
+	if s.Method != "" && !strings.EqualFold(s.Method, req.Method) {
+		return false
+	}
+	if !Host(s.Host, req.URL.Host) {
+		return false
+	}
+	if s.Path != nil && !s.Path.MatchString(req.URL.Path) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,31 @@
+package match
+
+import "testing"
+
+func TestHost(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"", "example.com:443", true},
+		{"example.com", "example.com", true},
+		{"example.com", "example.com:443", true},
+		{"example.com:443", "example.com:443", true},
+		{"example.com:443", "example.com", false},
+		{"localhost:3000", "localhost:3000", true},
+		{"localhost:3000", "localhost:8080", false},
+		{"localhost:*", "localhost:8080", true},
+		{"*.example.com", "api.example.com:8443", true},
+		{"*.example.com", "example.com", false},
+		{"EXAMPLE.com", "example.COM", true},
+		{"example.org", "example.com:443", false},
+		{"::1", "[::1]:443", true},
+		{"[::1]:443", "[::1]:443", true},
+		{"[::1]:443", "[::1]:8080", false},
+	}
+	for _, tt := range tests {
+		if got := Host(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("Host(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package mock answers requests from canned responses, typically created
+// from captured request/response pairs, without contacting upstream.
+//
+// A mock matches on method, host glob, path regexp, a set of query
+// parameters that must be present and, optionally, the request body
+// (compared as JSON when both sides parse). Templated bodies are rendered
+// with text/template and can refer to the incoming request:
+//
+//	{"id": "{{.Query.Get "id"}}", "echo": {{json .JSON.name}}, "at": "{{now}}"}
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+// Response is a mocked answer to be served instead of upstream.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// TemplateData is what templated mock bodies are rendered with.
+type TemplateData struct {
+	Method string
+	Host   string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   string
+	JSON   any // request body decoded as JSON, nil if it isn't
+}
+
+var funcs = template.FuncMap{
+	"now": func() string { return time.Now().UTC().Format(time.RFC3339) },
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+type compiled struct {
+	storage.Mock
+	scope match.Scope
+	query url.Values
+	body  any // decoded MatchBody when it is JSON
+	tmpl  *template.Template
+}
+
+var (
+	mu     sync.RWMutex
+	active []compiled
+)
+
+func compile(m storage.Mock) (compiled, error) {
+	c := compiled{Mock: m}
+	if m.Status != 0 && (m.Status < 100 || m.Status > 999) {
+		return c, fmt.Errorf("bad status %d", m.Status)
+	}
+	scope, err := match.NewScope(m.Host, m.Path, m.Method)
+	if err != nil {
+		return c, err
+	}
+	c.scope = scope
+
+	query, err := url.ParseQuery(m.Query)
+	if err != nil {
+		return c, fmt.Errorf("bad query: %w", err)
+	}
+	c.query = query
+
+	if m.MatchBody != "" {
+		var v any
+		if json.Unmarshal([]byte(m.MatchBody), &v) == nil {
+			c.body = v
+		}
+	}
+	if m.Template {
+		tmpl, err := template.New(fmt.Sprintf("mock %d", m.ID)).Funcs(funcs).Parse(m.Body)
+		if err != nil {
+			return c, fmt.Errorf("bad template: %w", err)
+		}
+		c.tmpl = tmpl
+	}
+	return c, nil
+}
+
+// Validate checks that m can be compiled.
+func Validate(m storage.Mock) error {
+	_, err := compile(m)
+	return err
+}
+
+// Load replaces the active mocks with the enabled ones from storage.
+func Load() error {
+	stored, err := storage.ListMocks()
+	if err != nil {
+		return err
+	}
+	var next []compiled
+	for _, m := range stored {
+		if !m.Enabled {
+			continue
+		}
+		c, err := compile(m)
+		if err != nil {
+			log.Printf("Skipping mock %d: %v", m.ID, err)
+			continue
+		}
+		next = append(next, c)
+	}
+
+	mu.Lock()
+	active = next
+	mu.Unlock()
+	return nil
+}
+
+// FromCapture builds a mock replaying the stored request requestID and
+// its latest response. The mock matches the exact method, host, path,
+// query and body of the capture.
+func FromCapture(requestID int) (*storage.Mock, error) {
+	req, err := storage.GetRequestByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := storage.GetResponseByRequestID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	return fromCapture(requestID, req, resp)
+}
+
+func fromCapture(requestID int, req *http.Request, resp *storage.ResponseInfo) (*storage.Mock, error) {
+	var header http.Header
+	if err := json.Unmarshal(resp.Headers, &header); err != nil {
+		return nil, fmt.Errorf("unmarshal response headers: %w", err)
+	}
+	// The stored body is already decoded and is re-framed when served
+	for _, h := range []string{"Content-Length", "Content-Encoding", "Transfer-Encoding"} {
+		header.Del(h)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+
+	return &storage.Mock{
+		Name:      fmt.Sprintf("%s %s", req.Method, req.URL.Path),
+		Enabled:   true,
+		RequestID: requestID,
+		Method:    req.Method,
+		Host:      req.URL.Hostname(),
+		Path:      "^" + regexp.QuoteMeta(req.URL.Path) + "$",
+		Query:     req.URL.RawQuery,
+		MatchBody: string(body),
+		Status:    resp.StatusCode,
+		Headers:   header,
+		Body:      resp.Body,
+	}, nil
+}
+
+func (c *compiled) matches(req *http.Request, body []byte) bool {
+	if !c.scope.Matches(req) {
+		return false
+	}
+	query := req.URL.Query()
+	for k, want := range c.query {
+		got := query[k]
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+	}
+	if c.MatchBody == "" {
+		return true
+	}
+	if c.body != nil {
+		var v any
+		if json.Unmarshal(body, &v) == nil {
+			return reflect.DeepEqual(c.body, v)
+		}
+	}
+	return strings.TrimSpace(c.MatchBody) == strings.TrimSpace(string(body))
+}
+
+// Match returns the response of the first mock matching req, or nil.
+func Match(req *http.Request, body []byte) *Response {
+	mu.RLock()
+	var m *compiled
+	for i := range active {
+		if active[i].matches(req, body) {
+			m = &active[i]
+			break
+		}
+	}
+	mu.RUnlock()
+	if m == nil {
+		return nil
+	}
+
+	header := m.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	status := m.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	out := []byte(m.Body)
+	if m.tmpl != nil {
+		data := TemplateData{
+			Method: req.Method,
+			Host:   req.URL.Host,
+			Path:   req.URL.Path,
+			Query:  req.URL.Query(),
+			Header: req.Header,
+			Body:   string(body),
+		}
+		json.Unmarshal(body, &data.JSON)
+		var buf bytes.Buffer
+		if err := m.tmpl.Execute(&buf, data); err != nil {
+			log.Printf("Mock %d: %v", m.ID, err)
+			return &Response{
+				Status: http.StatusInternalServerError,
+				Header: http.Header{"Content-Type": {"text/plain"}},
+				Body:   []byte("mock template: " + err.Error()),
+			}
+		}
+		out = buf.Bytes()
+	}
+	return &Response{Status: status, Header: header, Body: out}
+}
@@ -0,0 +1,42 @@
+package mock
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// Mocks created from HTTPS captures leave the port out of their host, so
+// they match with or without it.
+func TestFromCaptureHTTPS(t *testing.T) {
+	captured := httptest.NewRequest("GET", "https://example.com:443/api/items?id=7", nil)
+	resp := &storage.ResponseInfo{StatusCode: 200, Headers: []byte(`{"Content-Length":["2"]}`), Body: "[]"}
+
+	m, err := fromCapture(1, captured, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Host != "example.com" {
+		t.Errorf("Host = %q, want example.com", m.Host)
+	}
+
+	c, err := compile(*m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{"https://example.com:443/api/items?id=7", "https://example.com/api/items?id=7"} {
+		if !c.matches(httptest.NewRequest("GET", url, nil), nil) {
+			t.Errorf("mock from capture does not match %s", url)
+		}
+	}
+
+	m.Host = "example.com:8443"
+	c, _ = compile(*m)
+	if !c.matches(httptest.NewRequest("GET", "https://example.com:8443/api/items?id=7", nil), nil) {
+		t.Error("mock with a port in its host does not match that port")
+	}
+	if c.matches(httptest.NewRequest("GET", "https://example.com:443/api/items?id=7", nil), nil) {
+		t.Error("mock with a port in its host matches another port")
+	}
+}
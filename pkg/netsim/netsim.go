@@ -0,0 +1,212 @@
+// Package netsim simulates bad networks: added latency, bandwidth limits,
+// connection resets and injected error statuses, per host or per rule.
+//
+// Intercepted traffic is matched on host, path and method and delayed as
+// a whole exchange. Blind tunnels (HTTPS without MITM, protocol upgrades)
+// only carry a host, so they use rules without a path or method and are
+// throttled byte by byte with Wrap.
+package netsim
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+// ErrReset is returned by wrapped tunnels that were reset on purpose.
+var ErrReset = errors.New("netsim: connection reset")
+
+// Rule is a compiled storage.NetworkRule.
+type Rule struct {
+	storage.NetworkRule
+	scope match.Scope
+}
+
+var (
+	mu     sync.RWMutex
+	active []*Rule
+)
+
+func compile(r storage.NetworkRule) (*Rule, error) {
+	if r.LatencyMS < 0 || r.JitterMS < 0 || r.UploadKbps < 0 || r.DownloadKbps < 0 {
+		return nil, fmt.Errorf("latency, jitter and bandwidth can't be negative")
+	}
+	if r.ResetRate < 0 || r.ResetRate > 1 || r.ErrorRate < 0 || r.ErrorRate > 1 {
+		return nil, fmt.Errorf("reset_rate and error_rate must be between 0 and 1")
+	}
+	if r.ErrorRate > 0 && (r.ErrorStatus < 100 || r.ErrorStatus > 999) {
+		return nil, fmt.Errorf("bad error_status %d", r.ErrorStatus)
+	}
+	scope, err := match.NewScope(r.Host, r.Path, r.Method)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{NetworkRule: r, scope: scope}, nil
+}
+
+// Validate checks that r can be compiled.
+func Validate(r storage.NetworkRule) error {
+	_, err := compile(r)
+	return err
+}
+
+// Load replaces the active rules with the enabled ones from storage.
+func Load() error {
+	stored, err := storage.ListNetworkRules()
+	if err != nil {
+		return err
+	}
+	var next []*Rule
+	for _, r := range stored {
+		if !r.Enabled {
+			continue
+		}
+		c, err := compile(r)
+		if err != nil {
+			log.Printf("Skipping network rule %d: %v", r.ID, err)
+			continue
+		}
+		next = append(next, c)
+	}
+
+	mu.Lock()
+	active = next
+	mu.Unlock()
+	return nil
+}
+
+// Match returns the first rule matching req, or nil.
+func Match(req *http.Request) *Rule {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range active {
+		if r.scope.Matches(req) {
+			return r
+		}
+	}
+	return nil
+}
+
+// MatchHost returns the first host-only rule matching host, or nil.
+func MatchHost(host string) *Rule {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range active {
+		if r.Path == "" && r.Method == "" && match.Host(r.Host, host) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Delay sleeps for the rule's latency plus a random jitter.
+func (r *Rule) Delay() {
+	d := time.Duration(r.LatencyMS) * time.Millisecond
+	if r.JitterMS > 0 {
+		d += time.Duration(rand.IntN(2*r.JitterMS+1)-r.JitterMS) * time.Millisecond
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Upload and Download sleep for as long as sending n bytes takes at the
+// rule's bandwidth.
+func (r *Rule) Upload(n int)   { transfer(n, r.UploadKbps) }
+func (r *Rule) Download(n int) { transfer(n, r.DownloadKbps) }
+
+func transfer(n, kbps int) {
+	if kbps > 0 && n > 0 {
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(kbps*125))
+	}
+}
+
+// Reset and Fail roll the dice for a connection reset or an injected
+// error status.
+func (r *Rule) Reset() bool { return r.ResetRate > 0 && rand.Float64() < r.ResetRate }
+func (r *Rule) Fail() bool  { return r.ErrorRate > 0 && rand.Float64() < r.ErrorRate }
+
+// Wrap applies the host-only rule for host to a tunnel to upstream:
+// latency once on connect, throttled reads (download) and writes (upload),
+// and possibly a reset after a random amount of traffic.
+func Wrap(upstream io.ReadWriteCloser, host string) io.ReadWriteCloser {
+	r := MatchHost(host)
+	if r == nil {
+		return upstream
+	}
+	r.Delay()
+	c := &conn{ReadWriteCloser: upstream, rule: r, resetAfter: -1}
+	if r.Reset() {
+		c.resetAfter = rand.Int64N(64 << 10)
+	}
+	return c
+}
+
+type conn struct {
+	io.ReadWriteCloser
+	rule *Rule
+
+	mu         sync.Mutex
+	total      int64
+	resetAfter int64 // -1 for never
+}
+
+// chunk bounds a single read or write to about 100ms of traffic so the
+// throttling stays smooth.
+func chunk(p []byte, kbps int) []byte {
+	if kbps <= 0 {
+		return p
+	}
+	limit := max(kbps*125/10, 512)
+	if len(p) > limit {
+		return p[:limit]
+	}
+	return p
+}
+
+// count records n bytes of traffic and resets the tunnel once it is due.
+func (c *conn) count(n int) error {
+	c.mu.Lock()
+	c.total += int64(n)
+	reset := c.resetAfter >= 0 && c.total >= c.resetAfter
+	c.mu.Unlock()
+	if reset {
+		c.Close()
+		return ErrReset
+	}
+	return nil
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(chunk(p, c.rule.DownloadKbps))
+	c.rule.Download(n)
+	if rerr := c.count(n); rerr != nil {
+		return n, rerr
+	}
+	return n, err
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		part := chunk(p[written:], c.rule.UploadKbps)
+		n, err := c.ReadWriteCloser.Write(part)
+		written += n
+		c.rule.Upload(n)
+		if rerr := c.count(n); rerr != nil {
+			return written, rerr
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,413 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"MITM_PROXY/pkg/grpc"
+	"MITM_PROXY/pkg/intercept"
+	"MITM_PROXY/pkg/mock"
+	"MITM_PROXY/pkg/netsim"
+	"MITM_PROXY/pkg/rules"
+	"MITM_PROXY/pkg/scanner"
+	"MITM_PROXY/pkg/scope"
+	"MITM_PROXY/pkg/script"
+	"MITM_PROXY/pkg/sse"
+	"MITM_PROXY/pkg/storage"
+)
+
+// Built-in handlers backing the features managed through the web API.
+// Unless noted, each implements both RequestHandler and ResponseHandler.
+var (
+	// Network simulates latency, bandwidth limits, resets and errors as
+	// configured in pkg/netsim, throttling streamed bodies as they pass.
+	// Register it first so the delays cover the whole exchange.
+	Network = networkHandler{}
+	// Rules applies the rewrite rules loaded by rules.Load.
+	Rules = rulesHandler{}
+	// MapRules serves map-local files and retargets map-remote requests.
+	// It only handles requests.
+	MapRules = mapRulesHandler{}
+	// Mocks answers requests matching a mock from pkg/mock. It only
+	// handles requests.
+	Mocks = mocksHandler{}
+	// Scripts runs the Starlark hooks loaded by script.Load.
+	Scripts = scriptsHandler{}
+	// Intercept holds in-scope traffic, WebSocket messages included, for
+	// manual review as configured in pkg/intercept.
+	Intercept = interceptHandler{}
+	// Capture stores in-scope traffic, WebSocket messages and the events
+	// of Server-Sent Events streams with pkg/storage, gRPC messages with
+	// pkg/grpc, and feeds the passive scanner. Streamed responses are
+	// stored when their headers arrive and completed when they end. It
+	// requires storage.Init.
+	Capture = captureHandler{}
+)
+
+type networkHandler struct{}
+
+func (networkHandler) HandleRequest(ctx *Context) error {
+	r := netsim.Match(ctx.Request)
+	if r == nil {
+		return nil
+	}
+	ctx.Values["netsim"] = r
+	r.Delay()
+	r.Upload(len(ctx.RequestBody))
+	if r.Reset() {
+		return ErrReset
+	}
+	if r.Fail() && ctx.Response == nil {
+		body := []byte("netsim: injected error")
+		ctx.Response = NewResponse(ctx.Request, r.ErrorStatus, http.Header{"Content-Type": {"text/plain"}}, body)
+		ctx.ResponseBody = body
+	}
+	return nil
+}
+
+func (networkHandler) HandleResponse(ctx *Context) error {
+	if r, ok := ctx.Values["netsim"].(*netsim.Rule); ok {
+		r.Download(len(ctx.ResponseBody))
+	}
+	return nil
+}
+
+func (networkHandler) HandleStreamData(ctx *Context, fromClient bool, data []byte) error {
+	r, ok := ctx.Values["netsim"].(*netsim.Rule)
+	if !ok {
+		return nil
+	}
+	if fromClient {
+		r.Upload(len(data))
+	} else {
+		r.Download(len(data))
+	}
+	return nil
+}
+
+func (networkHandler) HandleStreamEnd(ctx *Context, fromClient bool, err error) {}
+
+type rulesHandler struct{}
+
+func (rulesHandler) HandleRequest(ctx *Context) error {
+	ctx.RequestBody = rules.ApplyRequest(ctx.Request, ctx.RequestBody)
+	return nil
+}
+
+func (rulesHandler) HandleResponse(ctx *Context) error {
+	ctx.ResponseBody = rules.ApplyResponse(ctx.Request, ctx.Response, ctx.ResponseBody)
+	return nil
+}
+
+type mapRulesHandler struct{}
+
+func (mapRulesHandler) HandleRequest(ctx *Context) error {
+	if ctx.Response != nil {
+		return nil
+	}
+	if mapped := rules.MapRequest(ctx.Request); mapped != nil {
+		ctx.Response = NewResponse(ctx.Request, mapped.Status, mapped.Header, mapped.Body)
+		ctx.ResponseBody = mapped.Body
+	}
+	return nil
+}
+
+type mocksHandler struct{}
+
+func (mocksHandler) HandleRequest(ctx *Context) error {
+	if ctx.Response != nil {
+		return nil
+	}
+	if m := mock.Match(ctx.Request, ctx.RequestBody); m != nil {
+		ctx.Response = NewResponse(ctx.Request, m.Status, m.Header, m.Body)
+		ctx.ResponseBody = m.Body
+	}
+	return nil
+}
+
+type scriptsHandler struct{}
+
+func (scriptsHandler) HandleRequest(ctx *Context) error {
+	ctx.RequestBody = script.OnRequest(ctx.Request, ctx.RequestBody)
+	return nil
+}
+
+func (scriptsHandler) HandleResponse(ctx *Context) error {
+	ctx.ResponseBody = script.OnResponse(ctx.Request, ctx.Response, ctx.ResponseBody)
+	return nil
+}
+
+type interceptHandler struct{}
+
+func (interceptHandler) HandleRequest(ctx *Context) error {
+	if !scope.InScope(ctx.Request) {
+		return nil
+	}
+	req, body, drop := intercept.Request(ctx.Request, ctx.RequestBody)
+	if drop {
+		return ErrDrop
+	}
+	ctx.Request, ctx.RequestBody = req, body
+	return nil
+}
+
+func (interceptHandler) HandleResponse(ctx *Context) error {
+	if !scope.ResponseInScope(ctx.Request, ctx.Response) {
+		return nil
+	}
+	resp, body, drop := intercept.Response(ctx.Request, ctx.Response, ctx.ResponseBody)
+	if drop {
+		return ErrDrop
+	}
+	ctx.Response, ctx.ResponseBody = resp, body
+	return nil
+}
+
+func (interceptHandler) HandleWebSocketMessage(msg *WebSocketMessage) error {
+	if !scope.InScope(msg.Context.Request) {
+		return nil
+	}
+	data, drop := intercept.WebSocketMessage(msg.Context.Request, msg.FromClient, msg.Binary, msg.Data)
+	if drop {
+		return ErrDrop
+	}
+	msg.Data = data
+	return nil
+}
+
+type captureHandler struct{}
+
+func (captureHandler) HandleRequest(ctx *Context) error {
+	if !scope.InScope(ctx.Request) {
+		return nil
+	}
+	id, err := storage.SaveRequest(ctx.Request, ctx.RequestBody)
+	if err != nil {
+		log.Printf("Error saving %s request: %v", ctx.Tag, err)
+	}
+	ctx.RequestID = id
+	log.Printf("[%s] #%d => %s %s", ctx.Tag, id, ctx.Request.Method, ctx.Request.URL.String())
+	if id == 0 {
+		return nil
+	}
+	if ctx.TLS != nil {
+		recordTLS(ctx.TLS, id)
+	}
+	st := &captureState{}
+	if grpc.IsGRPC(ctx.Request.Header) {
+		st.requestGRPC = grpc.NewRecorder(id, ctx.Request.URL.Path, grpc.DirectionRequest, ctx.Request.Header)
+		if !ctx.RequestStreamed {
+			st.requestGRPC.Write(ctx.RequestBody)
+			st.requestGRPC.Close()
+		}
+	}
+	ctx.Values["capture"] = st
+	return nil
+}
+
+// captureState follows the streamed bodies of a captured exchange. The
+// request fields are only used by the request body and the response
+// fields by the response, so the directions need no locking.
+type captureState struct {
+	dropped atomic.Bool // the request went out of scope
+
+	requestGRPC *grpc.Recorder
+
+	responseID   int
+	responseGRPC *grpc.Recorder
+	sse          *sse.Parser
+	sseEvents    int
+}
+
+// maxSSEEvents caps the events stored per Server-Sent Events stream,
+// independently of how much of its body is captured.
+const maxSSEEvents = 10000
+
+// recordTLS links request #requestID to its TLS connection, storing the
+// connection first if RecordTLSConnection didn't.
+func recordTLS(info *TLSInfo, requestID int) {
+	RecordTLSConnection(info)
+	if info.ID == 0 {
+		return
+	}
+	if err := storage.SetRequestTLSConnection(requestID, info.ID); err != nil {
+		log.Printf("Error linking request #%d to TLS connection: %v", requestID, err)
+	}
+}
+
+// RecordTLSConnection stores info with pkg/storage, once per connection,
+// and keeps its ID in info.ID. It suits Options.OnTLSConnection.
+func RecordTLSConnection(info *TLSInfo) {
+	info.saveOnce.Do(func() {
+		c := &storage.TLSConnection{
+			Host:        info.Host,
+			SNI:         info.ServerName,
+			Version:     tls.VersionName(info.Version),
+			CipherSuite: tls.CipherSuiteName(info.CipherSuite),
+			ALPN:        info.ALPN,
+			OfferedALPN: info.OfferedALPN,
+			JA3:         info.JA3,
+			JA3Hash:     info.JA3Hash,
+			JA4:         info.JA4,
+		}
+		for _, v := range info.OfferedVersions {
+			c.OfferedVersions = append(c.OfferedVersions, tls.VersionName(v))
+		}
+		for _, cs := range info.OfferedCiphers {
+			c.OfferedCiphers = append(c.OfferedCiphers, tls.CipherSuiteName(cs))
+		}
+		if err := storage.SaveTLSConnection(c); err != nil {
+			log.Printf("Error saving TLS connection to %s: %v", info.Host, err)
+			return
+		}
+		info.ID = c.ID
+	})
+}
+
+// HandleResponse stores the response and hands the exchange to the
+// passive scanner.
+func (captureHandler) HandleResponse(ctx *Context) error {
+	if ctx.RequestID == 0 {
+		return nil
+	}
+	st, _ := ctx.Values["capture"].(*captureState)
+	if !scope.ResponseInScope(ctx.Request, ctx.Response) {
+		if st != nil {
+			st.dropped.Store(true)
+		}
+		if err := storage.DeleteRequest(ctx.RequestID); err != nil {
+			log.Printf("Error dropping out of scope request #%d: %v", ctx.RequestID, err)
+		}
+		ctx.RequestID = 0
+		return nil
+	}
+	if ctx.Streamed {
+		respID, err := storage.SaveStreamedResponse(ctx.RequestID, ctx.Response)
+		if err != nil {
+			log.Printf("Error saving response for #%d: %v", ctx.RequestID, err)
+			return nil
+		}
+		st.responseID = respID
+		if grpc.IsGRPC(ctx.Response.Header) {
+			st.responseGRPC = grpc.NewRecorder(ctx.RequestID, ctx.Request.URL.Path, grpc.DirectionResponse, ctx.Response.Header)
+		} else if sse.IsEventStream(ctx.Response.Header) {
+			st.sse = &sse.Parser{MaxLine: ctx.Proxy.streamCaptureLimit()}
+		}
+		return nil
+	}
+	respID, err := storage.SaveResponse(ctx.RequestID, ctx.Response, ctx.ResponseBody)
+	if err != nil {
+		log.Printf("Error saving response for #%d: %v", ctx.RequestID, err)
+		return nil
+	}
+	scanner.Passive(ctx.RequestID, respID, ctx.Request, ctx.Response, ctx.ResponseBody)
+	return nil
+}
+
+// HandleStreamData stores the messages of gRPC calls, and the first
+// maxSSEEvents events of Server-Sent Events streams, as they pass.
+func (captureHandler) HandleStreamData(ctx *Context, fromClient bool, data []byte) error {
+	st, ok := ctx.Values["capture"].(*captureState)
+	if !ok {
+		return nil
+	}
+	if fromClient {
+		if st.requestGRPC != nil && !st.dropped.Load() {
+			st.requestGRPC.Write(data)
+		}
+		return nil
+	}
+	if st.responseGRPC != nil {
+		st.responseGRPC.Write(data)
+	}
+	if st.sse == nil {
+		return nil
+	}
+	for _, ev := range st.sse.Feed(data) {
+		if st.sseEvents == maxSSEEvents {
+			log.Printf("SSE stream of #%d passed %d events, not storing more", ctx.RequestID, maxSSEEvents)
+			st.sse = nil
+			break
+		}
+		st.sseEvents++
+		e := &storage.SSEEvent{RequestID: ctx.RequestID, EventID: ev.ID, Type: ev.Type, Data: ev.Data}
+		if ev.Retry >= 0 {
+			e.Retry = &ev.Retry
+		}
+		if err := storage.SaveSSEEvent(e); err != nil {
+			log.Printf("Error saving SSE event of #%d: %v", ctx.RequestID, err)
+		}
+	}
+	return nil
+}
+
+// HandleStreamEnd stores the captured body of a streamed response and
+// hands the exchange to the passive scanner.
+func (captureHandler) HandleStreamEnd(ctx *Context, fromClient bool, err error) {
+	st, ok := ctx.Values["capture"].(*captureState)
+	if !ok {
+		return
+	}
+	if fromClient {
+		if st.requestGRPC != nil && !st.dropped.Load() {
+			st.requestGRPC.Close()
+		}
+		return
+	}
+	if st.responseID == 0 {
+		return
+	}
+	if st.responseGRPC != nil {
+		st.responseGRPC.Close()
+	}
+	if err != nil {
+		log.Printf("Stream of #%d ended early: %v", ctx.RequestID, err)
+	}
+	if err := storage.FinishStreamedResponse(st.responseID, ctx.Response, ctx.ResponseBody, ctx.ResponseTruncated); err != nil {
+		log.Printf("Error saving streamed response of #%d: %v", ctx.RequestID, err)
+		return
+	}
+	scanner.Passive(ctx.RequestID, st.responseID, ctx.Request, ctx.Response, ctx.ResponseBody)
+}
+
+// HandleWebSocketMessage stores msg with the captured handshake request.
+func (captureHandler) HandleWebSocketMessage(msg *WebSocketMessage) error {
+	if msg.Context.RequestID == 0 {
+		return nil
+	}
+	m := &storage.WebSocketMessage{
+		RequestID: msg.Context.RequestID,
+		Direction: "server",
+		Type:      "text",
+		Data:      msg.Data,
+		CreatedAt: msg.Time,
+	}
+	if msg.FromClient {
+		m.Direction = "client"
+	}
+	if msg.Binary {
+		m.Type = "binary"
+	}
+	if err := storage.SaveWebSocketMessage(m); err != nil {
+		log.Printf("Error saving WebSocket message of #%d: %v", msg.Context.RequestID, err)
+	}
+	return nil
+}
+
+// RecordTunnel stores t with pkg/storage. It suits Options.OnTunnel.
+func RecordTunnel(t Tunnel) {
+	err := storage.SaveTunnel(&storage.Tunnel{
+		Host:       t.Host,
+		SNI:        t.SNI,
+		Reason:     t.Reason,
+		StartedAt:  t.Start,
+		DurationMS: t.Duration.Milliseconds(),
+		BytesUp:    t.BytesUp,
+		BytesDown:  t.BytesDown,
+	})
+	if err != nil {
+		log.Printf("Error saving tunnel to %s: %v", t.Host, err)
+	}
+}
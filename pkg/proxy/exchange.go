@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+)
+
+// exchange runs req through the handler chain, forwards it upstream unless
+// a handler answered it, and returns the finished exchange. Its Response
+// is the one for the client, with the body fully buffered unless it is
+// streamed; for 101 Switching Protocols the body is the upgraded upstream
+// connection.
+func (p *Proxy) exchange(conn net.Conn, tag string, info *TLSInfo, req *http.Request) (*Context, error) {
+	var body []byte
+	clientBody := req.Body
+	requestStreamed := streamingRequest(req)
+	if !requestStreamed {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+	}
+	req.Header.Del("Proxy-Connection")
+	if len(p.wsHandlers) > 0 && isWebSocket(req.Header) {
+		// Compressed frames could be neither read nor edited
+		req.Header.Del("Sec-WebSocket-Extensions")
+	}
+
+	ctx := &Context{
+		Proxy:           p,
+		Conn:            conn,
+		Tag:             tag,
+		TLS:             info,
+		Request:         req,
+		RequestBody:     body,
+		RequestStreamed: requestStreamed,
+		Values:          map[string]any{},
+	}
+	for _, h := range p.requestHandlers {
+		if err := h.HandleRequest(ctx); err != nil {
+			if requestStreamed {
+				clientBody.Close()
+			}
+			return nil, err
+		}
+	}
+
+	if requestStreamed && ctx.Response != nil {
+		clientBody.Close()
+	}
+	if ctx.Response == nil {
+		if requestStreamed {
+			p.setStreamRequestBody(ctx, clientBody)
+		} else {
+			setRequestBody(ctx.Request, ctx.RequestBody)
+		}
+		ctx.Request.RequestURI = ""
+		upstreamReq := ctx.Request
+		if ctx.TLS != nil {
+			upstreamReq = upstreamReq.WithContext(context.WithValue(upstreamReq.Context(), tlsInfoKey{}, ctx.TLS))
+		}
+		resp, err := p.transport.RoundTrip(upstreamReq)
+		if err != nil {
+			return nil, fmt.Errorf("forward request: %w", err)
+		}
+		ctx.Response = resp
+
+		if p.streaming(resp) {
+			ctx.Streamed = true
+		} else if resp.StatusCode != http.StatusSwitchingProtocols {
+			ctx.ResponseBody, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("read response body: %w", err)
+			}
+		}
+	}
+	if ctx.Response.Request == nil {
+		ctx.Response.Request = ctx.Request
+	}
+
+	var upstreamBody io.ReadCloser
+	if ctx.Streamed {
+		upstreamBody = ctx.Response.Body
+	}
+	for _, h := range p.responseHandlers {
+		if err := h.HandleResponse(ctx); err != nil {
+			if upstreamBody != nil {
+				upstreamBody.Close()
+			}
+			return nil, err
+		}
+	}
+
+	if ctx.Streamed {
+		p.setStreamBody(ctx, upstreamBody)
+	} else if ctx.Response.StatusCode != http.StatusSwitchingProtocols {
+		setResponseBody(ctx.Response, ctx.ResponseBody)
+	}
+	return ctx, nil
+}
+
+func setRequestBody(req *http.Request, body []byte) {
+	req.TransferEncoding = nil
+	req.Header.Del("Transfer-Encoding")
+	if len(req.Trailer) > 0 {
+		// HTTP/1.1 only carries trailers in chunked bodies
+		req.ContentLength = -1
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return
+	}
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		req.Body = http.NoBody
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+}
+
+// setResponseBody replaces the body of resp with the buffered one and
+// fixes framing, leaving bodiless responses (HEAD, 204, 304) alone.
+func setResponseBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	// Written to HTTP/1 clients as is; HTTP/2 responses are framed by
+	// serveHTTP2 regardless
+	if resp.ProtoMajor != 1 {
+		resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+	}
+	if (resp.Request != nil && resp.Request.Method == http.MethodHead) ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return
+	}
+	if len(resp.Trailer) > 0 {
+		// HTTP/1.1 only carries trailers in chunked bodies
+		resp.ContentLength = -1
+		resp.TransferEncoding = []string{"chunked"}
+		return
+	}
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+}
+
+// tunnelUpgrade completes a protocol upgrade towards the client and then
+// relays the connection: message by message for WebSocket when there are
+// WebSocket handlers, blindly otherwise.
+func (p *Proxy) tunnelUpgrade(client io.ReadWriter, clientReader *bufio.Reader, ctx *Context) {
+	resp := ctx.Response
+	body, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		log.Println("Upgrade response has no writable body")
+		return
+	}
+	upstream := p.wrapTunnel(body, resp.Request.URL.Host)
+	defer upstream.Close()
+
+	fmt.Fprintf(client, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(client)
+	io.WriteString(client, "\r\n")
+	if f, ok := client.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+
+	if len(p.wsHandlers) > 0 && isWebSocket(resp.Header) {
+		p.relayWebSocket(ctx, client, clientReader, upstream)
+		return
+	}
+	go io.Copy(upstream, clientReader)
+	io.Copy(client, upstream)
+}
+
+// resetConn closes conn with an RST instead of a FIN where possible.
+func resetConn(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+func writeError(w io.Writer, status int, msg string) {
+	fmt.Fprintf(w, "HTTP/1.1 %d %s\r\nContent-Type: text/plain\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+		status, http.StatusText(status), len(msg), msg)
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// grpcFrame frames msg as an uncompressed gRPC message.
+func grpcFrame(msg string) []byte {
+	b := []byte{0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// streamRecorder is a StreamHandler keeping the bodies it sees.
+type streamRecorder struct {
+	mu       sync.Mutex
+	request  bytes.Buffer
+	response bytes.Buffer
+}
+
+func (r *streamRecorder) HandleStreamData(ctx *Context, fromClient bool, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fromClient {
+		r.request.Write(data)
+	} else {
+		r.response.Write(data)
+	}
+	return nil
+}
+
+func (r *streamRecorder) HandleStreamEnd(ctx *Context, fromClient bool, err error) {}
+
+// TestGRPCBidiStream runs a bidirectional streaming call through the
+// proxy: every reply must arrive before the client sends the next message.
+func TestGRPCBidiStream(t *testing.T) {
+	up := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		hdr := make([]byte, 5)
+		for {
+			if _, err := io.ReadFull(r.Body, hdr); err != nil {
+				break
+			}
+			msg := make([]byte, binary.BigEndian.Uint32(hdr[1:]))
+			if _, err := io.ReadFull(r.Body, msg); err != nil {
+				break
+			}
+			w.Write(grpcFrame("echo " + string(msg)))
+			w.(http.Flusher).Flush()
+		}
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	}))
+	up.EnableHTTP2 = true
+	up.StartTLS()
+	defer up.Close()
+
+	p := New(testOptions(t))
+	rec := &streamRecorder{}
+	p.UseStream(rec)
+	c := startProxy(t, p, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pr, pw := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, up.URL+"/echo.Echo/Chat", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("proto = %s, want HTTP/2", resp.Proto)
+	}
+
+	for _, msg := range []string{"one", "two"} {
+		if _, err := pw.Write(grpcFrame(msg)); err != nil {
+			t.Fatalf("send %s: %v", msg, err)
+		}
+		want := grpcFrame("echo " + msg)
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(resp.Body, got); err != nil {
+			t.Fatalf("reply to %s: %v", msg, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("reply to %s = %q, want %q", msg, got, want)
+		}
+	}
+	pw.Close()
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Errorf("grpc-status trailer = %q, want 0", got)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if want := append(grpcFrame("one"), grpcFrame("two")...); !bytes.Equal(rec.request.Bytes(), want) {
+		t.Errorf("stream handlers saw request %q, want %q", rec.request.Bytes(), want)
+	}
+	if want := append(grpcFrame("echo one"), grpcFrame("echo two")...); !bytes.Equal(rec.response.Bytes(), want) {
+		t.Errorf("stream handlers saw response %q, want %q", rec.response.Bytes(), want)
+	}
+}
@@ -9,7 +9,8 @@ import (
 	"strings"
 )
 
-func HandleClient(conn net.Conn) {
+// HandleClient serves a single client connection.
+func (p *Proxy) HandleClient(conn net.Conn) {
 	defer conn.Close()
 
 	reader := bufio.NewReader(conn)
@@ -26,9 +27,12 @@ func HandleClient(conn net.Conn) {
 	}
 
 	if strings.ToUpper(method) == "CONNECT" {
-		handleHTTPS(conn, parsedUrl, versionProtocol, reader)
+		if p.blocked(conn, conn, parsedUrl, true) {
+			return
+		}
+		p.handleHTTPS(conn, parsedUrl, versionProtocol, reader)
 	} else {
-		handleHTTP(conn, method, versionProtocol, parsedUrl, reqLine, reader)
+		p.handleHTTP(conn, reqLine, reader)
 	}
 }
 
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/net/http2"
+)
+
+// hopHeaders are connection-specific and not allowed in HTTP/2.
+var hopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Upgrade"}
+
+// serveHTTP2 serves an HTTP/2 client over tlsClient. Every stream is its
+// own exchange, and streams run concurrently.
+func (p *Proxy) serveHTTP2(clientConn net.Conn, tlsClient *tls.Conn, target string, info *TLSInfo) {
+	srv := &http2.Server{}
+	srv.ServeConn(tlsClient, &http2.ServeConnOpts{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.URL.Scheme = "https"
+			req.URL.Host = target
+			// :authority is what the client asked for; keep it as Host
+			if req.Host == "" {
+				req.Host = target
+			}
+			if status, blocked := p.checkBlock(req.URL, false); blocked {
+				if status == 0 {
+					panic(http.ErrAbortHandler)
+				}
+				http.Error(w, "blocked by proxy", status)
+				return
+			}
+
+			ctx, err := p.exchange(clientConn, "HTTPS", info, req)
+			if err != nil {
+				// Drops and resets only abort the stream, the other
+				// streams on the connection carry on
+				if errors.Is(err, ErrDrop) || errors.Is(err, ErrReset) {
+					panic(http.ErrAbortHandler)
+				}
+				log.Println("Error forwarding HTTP/2 request:", err)
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer ctx.Response.Body.Close()
+			writeHTTP2Response(w, ctx.Response, ctx.Streamed)
+		}),
+	})
+}
+
+// writeHTTP2Response sends resp, trailers included, on an HTTP/2 stream.
+// Streamed bodies are flushed as they arrive.
+func writeHTTP2Response(w http.ResponseWriter, resp *http.Response, streamed bool) {
+	h := w.Header()
+	for k, vs := range resp.Header {
+		h[k] = vs
+	}
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+	// Handlers may have changed the body since upstream declared its length
+	h.Del("Content-Length")
+	if resp.ContentLength >= 0 {
+		h.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	for k := range resp.Trailer {
+		h.Add("Trailer", k)
+	}
+	w.WriteHeader(resp.StatusCode)
+	var dst io.Writer = w
+	if f, ok := w.(http.Flusher); ok && streamed {
+		// The client may wait for the headers before sending more
+		f.Flush()
+		dst = flushWriter{w: w, flush: f.Flush}
+	}
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		log.Println("Error writing HTTP/2 response:", err)
+		return
+	}
+	// gRPC servers don't declare their trailers, which upstream adds to
+	// resp.Trailer once the body ends
+	for k, vs := range resp.Trailer {
+		h[http.TrailerPrefix+k] = vs
+	}
+}
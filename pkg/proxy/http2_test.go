@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTP2EditedBody(t *testing.T) {
+	up := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		io.WriteString(w, "hello")
+	}))
+	up.EnableHTTP2 = true
+	up.StartTLS()
+	defer up.Close()
+
+	for _, body := range []string{"hello, edited world", "hi"} {
+		t.Run(body, func(t *testing.T) {
+			p := New(testOptions(t))
+			p.UseResponse(ResponseHandlerFunc(func(ctx *Context) error {
+				ctx.ResponseBody = []byte(body)
+				return nil
+			}))
+			c := startProxy(t, p, true)
+
+			resp, err := c.Get(up.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+			if resp.ProtoMajor != 2 {
+				t.Fatalf("proto = %s, want HTTP/2", resp.Proto)
+			}
+			if string(got) != body || resp.ContentLength != int64(len(body)) {
+				t.Errorf("got %q with length %d, want %q", got, resp.ContentLength, body)
+			}
+		})
+	}
+}
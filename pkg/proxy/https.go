@@ -2,8 +2,11 @@ package proxy
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"crypto/rsa"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,12 +14,14 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"MITM_PROXY/pkg/cert"
-	"MITM_PROXY/pkg/storage"
+	"MITM_PROXY/pkg/tlsinfo"
 )
 
-func handleHTTPS(clientConn net.Conn, parsedUrl *url.URL, versionProtocol string, reader *bufio.Reader) {
+func (p *Proxy) handleHTTPS(clientConn net.Conn, parsedUrl *url.URL, versionProtocol string, reader *bufio.Reader) {
 	// 1. Считаем и отбросим все заголовки CONNECT‑запроса
 	//    (reader уже прочитал только первую строку в parseRequestLine)
 	for {
@@ -34,51 +39,98 @@ func handleHTTPS(clientConn net.Conn, parsedUrl *url.URL, versionProtocol string
 	//    (можно использовать HTTP/1.0 или оригинальный versionProtocol)
 	fmt.Fprintf(clientConn, "%s 200 Connection established\r\n\r\n", versionProtocol)
 
-	// 3. Открываем TLS‑сессию к реальному серверу
 	hostPort := parsedUrl.Host
 	if !strings.Contains(hostPort, ":") {
 		hostPort += ":443"
 	}
-	rawServerConn, err := tls.Dial("tcp", hostPort, &tls.Config{
-		InsecureSkipVerify: true,
-	})
-	if err != nil {
-		log.Println("Error connecting to real TLS server:", err)
+
+	// Сырой ClientHello читаем заранее: по нему снимается отпечаток JA3/JA4,
+	// пока его не прочитал tls.Server, а клиент мог сделать CONNECT на
+	// IP‑адрес, поэтому pass-through решается по SNI, если он есть
+	reader = bufio.NewReaderSize(reader, tlsinfo.RecordSize)
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	hello, helloErr := tlsinfo.Peek(reader)
+	clientConn.SetReadDeadline(time.Time{})
+	name := parsedUrl.Hostname()
+	if helloErr == nil && hello.ServerName != "" {
+		name = hello.ServerName
+	}
+
+	if p.opts.PassThrough != nil && p.opts.PassThrough(name) {
+		p.tunnel(clientConn, reader, hostPort, "pass-through")
 		return
 	}
-	defer rawServerConn.Close()
 
-	// 4. Если есть CA — включаем MITM, иначе простое туннелирование
-	caCert, caKey := cert.GetCA()
+	// 3. Если есть CA — включаем MITM, иначе простое туннелирование
+	caCert, caKey := p.ca()
 	if caCert == nil || caKey == nil {
 		log.Println("CA not loaded, fallback to simple tunnel for HTTPS")
-		go io.Copy(rawServerConn, reader)  // client→server
-		io.Copy(clientConn, rawServerConn) // server→client
+		p.tunnel(clientConn, reader, hostPort, "no-ca")
 		return
 	}
 
-	// 5. Генерация MITM‑сертификата
-	mitmCert, err := cert.BuildCertificate(parsedUrl.Hostname(), caCert, caKey)
-	if err != nil {
-		log.Println("Cannot build certificate for host:", parsedUrl.Hostname(), err)
-		go io.Copy(rawServerConn, reader)
-		io.Copy(clientConn, rawServerConn)
-		return
+	// 4. Сертификат выбирается по SNI из ClientHello: клиент мог сделать
+	//    CONNECT на IP‑адрес. Без SNI используем хост из CONNECT
+	info := &TLSInfo{Host: hostPort}
+	if helloErr == nil {
+		info.JA3, info.JA3Hash = hello.JA3()
+		info.JA4 = hello.JA4()
+		info.ClientHello = hello.Raw
+	} else {
+		log.Println("Cannot parse ClientHello:", helloErr)
 	}
 
-	// 6. Устанавливаем TLS‑сервер поверх clientConn
-	tlsClient := tls.Server(clientConn, &tls.Config{
-		Certificates: []tls.Certificate{mitmCert},
-		ServerName:   parsedUrl.Hostname(),
+	tlsClient := tls.Server(readerConn{Conn: clientConn, r: reader}, &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			info.ServerName = hello.ServerName
+			info.OfferedVersions = hello.SupportedVersions
+			info.OfferedCiphers = hello.CipherSuites
+			info.OfferedALPN = hello.SupportedProtos
+
+			certName := hello.ServerName
+			if certName == "" {
+				certName = parsedUrl.Hostname()
+			}
+			// 5. Генерация MITM‑сертификата по образцу сертификата сервера
+			return p.forge(certName, hostPort, info, caCert, caKey)
+		},
+		NextProtos: []string{"h2", "http/1.1"},
 	})
 	defer tlsClient.Close()
 
-	if err := tlsClient.Handshake(); err != nil {
+	// 6. Рукопожатие с клиентом
+	err := tlsClient.Handshake()
+	if p.opts.OnHandshake != nil {
+		p.opts.OnHandshake(name, err)
+	}
+	if err != nil {
 		log.Println("TLS handshake with client failed:", err)
 		return
 	}
+	cs := tlsClient.ConnectionState()
+	info.Version = cs.Version
+	info.CipherSuite = cs.CipherSuite
+	info.ALPN = cs.NegotiatedProtocol
+	if p.opts.OnTLSConnection != nil {
+		p.opts.OnTLSConnection(info)
+	}
 
-	// 7. Переключаемся на зашифрованный поток и начинаем читать запросы
+	// Requests go to the name the client asked for in the SNI rather than
+	// a bare IP, which upstream certificates rarely cover
+	target := parsedUrl.Host
+	if info.ServerName != "" && net.ParseIP(parsedUrl.Hostname()) != nil {
+		target = info.ServerName
+		if port := parsedUrl.Port(); port != "" {
+			target = net.JoinHostPort(info.ServerName, port)
+		}
+	}
+
+	// 7. Переключаемся на зашифрованный поток и начинаем читать запросы.
+	//    HTTP/2 обслуживается отдельно, каждый поток — своим обменом
+	if info.ALPN == "h2" {
+		p.serveHTTP2(clientConn, tlsClient, target, info)
+		return
+	}
 	clientReader := bufio.NewReader(tlsClient)
 	clientWriter := bufio.NewWriter(tlsClient)
 
@@ -92,39 +144,130 @@ func handleHTTPS(clientConn net.Conn, parsedUrl *url.URL, versionProtocol string
 			break
 		}
 
-		// Считать тело (если есть)
-		var bodyBytes []byte
-		if req.ContentLength > 0 {
-			bodyBytes, _ = io.ReadAll(req.Body)
+		req.URL.Scheme = "https"
+		req.URL.Host = target
+		req.Host = target
+		if p.blocked(clientConn, clientWriter, req.URL, false) {
+			break
 		}
-		req.Body.Close()
 
-		// Логируем и сохраняем
-		id, err := storage.SaveRequest(req, bodyBytes)
+		// Сохраняем и отправляем запрос на реальный сервер
+		ctx, err := p.exchange(clientConn, "HTTPS", info, req)
 		if err != nil {
-			log.Printf("Error saving HTTPS request #%d: %v", id, err)
-			go io.Copy(rawServerConn, reader)
-			io.Copy(clientConn, rawServerConn)
+			if errors.Is(err, ErrReset) {
+				resetConn(clientConn)
+			} else if !errors.Is(err, ErrDrop) {
+				log.Println("Error forwarding HTTPS request:", err)
+				writeError(clientWriter, http.StatusBadGateway, err.Error())
+				clientWriter.Flush()
+			}
+			break
+		}
+
+		resp := ctx.Response
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			p.tunnelUpgrade(tlsClient, clientReader, ctx)
 			return
 		}
-		log.Printf("[HTTPS] #%d => %s %s", id, req.Method, req.URL.String())
 
-		req.URL.Scheme = "https"
-		req.URL.Host = parsedUrl.Host
-		req.Host = parsedUrl.Host
+		// Пересылаем ответ клиенту; потоковые ответы — по мере поступления
+		if ctx.Streamed {
+			resp.Write(flushWriter{w: clientWriter, flush: func() { clientWriter.Flush() }})
+		} else {
+			resp.Write(clientWriter)
+		}
+		clientWriter.Flush()
+	}
+}
 
-		// Восстанавливаем тело
-		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+// forge returns a certificate for serverName signed by the CA, mirroring
+// the certificate the server at hostPort presents for that name. Forged
+// certificates are cached for certCacheTTL.
+func (p *Proxy) forge(serverName, hostPort string, info *TLSInfo, caCert *x509.Certificate, caKey *rsa.PrivateKey) (*tls.Certificate, error) {
+	key := serverName + " " + hostPort
+	p.certMu.Lock()
+	c, ok := p.certs[key]
+	p.certMu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.cert, nil
+	}
 
-		// Отправляем запрос на реальный сервер
-		resp, err := http.DefaultTransport.RoundTrip(req)
-		if err != nil {
-			log.Println("Error forwarding HTTPS request:", err)
-			break
+	// Ошибка соединения с сервером не фатальна: запросы клиента получат
+	// 502 с её описанием
+	var upstreamCert *x509.Certificate
+	serverConn, err := p.dialTLS(context.Background(), hostPort, serverName, info)
+	if err != nil {
+		log.Println("Error connecting to real TLS server:", err)
+	} else {
+		if c, ok := serverConn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+			if certs := c.ConnectionState().PeerCertificates; len(certs) > 0 {
+				upstreamCert = certs[0]
+			}
 		}
+		serverConn.Close()
+	}
 
-		// Пересылаем ответ клиенту
-		resp.Write(clientWriter)
-		clientWriter.Flush()
+	mitmCert, err := cert.BuildCertificateFrom(serverName, upstreamCert, caCert, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot build certificate for %s: %w", serverName, err)
+	}
+
+	p.certMu.Lock()
+	if p.certs == nil {
+		p.certs = map[string]cachedCert{}
+	}
+	p.certs[key] = cachedCert{cert: &mitmCert, expires: time.Now().Add(certCacheTTL)}
+	p.certMu.Unlock()
+	return &mitmCert, nil
+}
+
+// tunnel relays the client's stream to hostPort without interception and
+// reports it to Options.OnTunnel.
+func (p *Proxy) tunnel(clientConn net.Conn, reader *bufio.Reader, hostPort, reason string) {
+	t := Tunnel{Host: hostPort, Reason: reason, Start: time.Now()}
+
+	// The client speaks first; peek at its ClientHello for the SNI
+	clientConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if hello, err := tlsinfo.Peek(reader); err == nil {
+		t.SNI = hello.ServerName
 	}
+	clientConn.SetReadDeadline(time.Time{})
+
+	serverConn, err := net.Dial("tcp", hostPort)
+	if err != nil {
+		log.Println("Error connecting to tunnel target:", err)
+		return
+	}
+	upstream := p.wrapTunnel(serverConn, hostPort)
+	defer upstream.Close()
+
+	up := &countingWriter{Writer: upstream}
+	go io.Copy(up, reader)                   // client→server
+	down, _ := io.Copy(clientConn, upstream) // server→client
+
+	if p.opts.OnTunnel != nil {
+		t.Duration = time.Since(t.Start)
+		t.BytesDown = down
+		t.BytesUp = up.n.Load()
+		p.opts.OnTunnel(t)
+	}
+}
+
+// readerConn reads through r, which may already hold bytes read off Conn.
+type readerConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c readerConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+type countingWriter struct {
+	io.Writer
+	n atomic.Int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.n.Add(int64(n))
+	return n, err
 }
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestOnTLSConnection checks that an intercepted connection is reported
+// once, after the handshake, before any handler sees its requests.
+func TestOnTLSConnection(t *testing.T) {
+	up := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer up.Close()
+
+	for _, h2 := range []bool{false, true} {
+		t.Run(map[bool]string{false: "HTTP/1.1", true: "HTTP/2"}[h2], func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				conns []*TLSInfo
+			)
+			opts := testOptions(t)
+			opts.OnTLSConnection = func(info *TLSInfo) {
+				mu.Lock()
+				defer mu.Unlock()
+				conns = append(conns, info)
+			}
+			p := New(opts)
+			p.UseRequest(RequestHandlerFunc(func(ctx *Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if len(conns) != 1 || conns[0] != ctx.TLS {
+					t.Errorf("request on a connection that wasn't reported")
+				}
+				return nil
+			}))
+			c := startProxy(t, p, h2)
+
+			for range 2 {
+				resp, err := c.Get(up.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(conns) != 1 {
+				t.Fatalf("reported %d connections, want 1", len(conns))
+			}
+			if conns[0].Version == 0 || h2 && conns[0].ALPN != "h2" {
+				t.Errorf("reported version %x and ALPN %q, want the negotiated ones", conns[0].Version, conns[0].ALPN)
+			}
+		})
+	}
+}
+
+// TestPassThroughSNI checks that CONNECTs to an IP address are judged by
+// the name in the SNI, for both pass-through and handshake reports.
+func TestPassThroughSNI(t *testing.T) {
+	up := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer up.Close()
+
+	for _, pass := range []bool{true, false} {
+		var (
+			mu        sync.Mutex
+			passHosts []string
+			hsHosts   []string
+		)
+		opts := testOptions(t)
+		opts.PassThrough = func(host string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			passHosts = append(passHosts, host)
+			return pass
+		}
+		opts.OnHandshake = func(host string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			hsHosts = append(hsHosts, host)
+		}
+		c := startProxy(t, New(opts), false)
+		tr := c.Transport.(*http.Transport)
+		tr.TLSClientConfig = &tls.Config{ServerName: "pinned.test", InsecureSkipVerify: true}
+
+		resp, err := c.Get(up.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		mu.Lock()
+		if len(passHosts) != 1 || passHosts[0] != "pinned.test" {
+			t.Errorf("pass-through %v: PassThrough asked for %q, want pinned.test", pass, passHosts)
+		}
+		if !pass && (len(hsHosts) != 1 || hsHosts[0] != "pinned.test") {
+			t.Errorf("OnHandshake told about %q, want pinned.test", hsHosts)
+		}
+		mu.Unlock()
+	}
+}
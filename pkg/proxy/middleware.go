@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrDrop can be returned by a handler to drop the exchange: nothing is
+// sent upstream or back and the client connection is closed.
+var ErrDrop = errors.New("proxy: exchange dropped")
+
+// ErrReset is like ErrDrop but resets the client connection instead of
+// closing it gracefully.
+var ErrReset = errors.New("proxy: connection reset")
+
+// Context carries one request/response exchange through the handler chain.
+// Bodies are fully buffered, except for streamed ones; handlers edit
+// RequestBody and ResponseBody rather than the Body readers.
+type Context struct {
+	Proxy *Proxy
+	Conn  net.Conn // client connection
+	Tag   string   // HTTP or HTTPS
+
+	// TLS describes the client's TLS connection; nil for plain HTTP. It is
+	// shared by all exchanges on the connection, which run concurrently
+	// for HTTP/2 clients.
+	TLS *TLSInfo
+
+	Request     *http.Request
+	RequestBody []byte
+
+	// RequestStreamed is set for gRPC calls from HTTP/2 clients, whose
+	// request body is forwarded as the client sends it so that streaming
+	// calls work. RequestBody is then empty, body edits are ignored and
+	// StreamHandlers see the body as it is forwarded.
+	RequestStreamed bool
+
+	// Response is nil until upstream answers. A request handler that sets
+	// it answers the client directly and the upstream round trip is skipped.
+	Response     *http.Response
+	ResponseBody []byte
+
+	// Streamed is set for responses forwarded to the client as they arrive
+	// rather than buffered: those of unknown length or larger than
+	// Options.StreamCaptureLimit, Server-Sent Events and gRPC. Response
+	// handlers then run as soon as the headers arrive, with an empty
+	// ResponseBody, and body edits are ignored. As the body is forwarded,
+	// StreamHandlers see it and ResponseBody collects up to
+	// Options.StreamCaptureLimit bytes of it; ResponseTruncated tells
+	// whether there was more.
+	Streamed          bool
+	ResponseTruncated bool
+
+	// RequestID is the storage ID of the request when Capture is in use.
+	RequestID int
+
+	// Values is free for handlers to share data within an exchange.
+	Values map[string]any
+}
+
+// TLSInfo describes an intercepted TLS connection from a client: what its
+// ClientHello offered and what was negotiated.
+type TLSInfo struct {
+	Host            string // CONNECT target
+	ServerName      string // SNI, empty if none was sent
+	OfferedVersions []uint16
+	OfferedCiphers  []uint16
+	OfferedALPN     []string
+	Version         uint16
+	CipherSuite     uint16
+	ALPN            string
+
+	// Client fingerprints computed from the raw ClientHello; empty if it
+	// could not be parsed.
+	JA3     string
+	JA3Hash string
+	JA4     string
+
+	// ClientHello is the raw ClientHello record, for Options.DialTLS.
+	ClientHello []byte
+
+	// ID is the storage ID of the connection once RecordTLSConnection or
+	// Capture stored it.
+	ID int
+
+	// saveOnce stores the connection once, whichever of the handshake and
+	// its concurrent HTTP/2 streams gets there first.
+	saveOnce sync.Once
+}
+
+// RequestHandler runs before a request is sent upstream. Every registered
+// request handler runs, in order, even after one of them set a response.
+type RequestHandler interface {
+	HandleRequest(ctx *Context) error
+}
+
+// ResponseHandler runs before a response is returned to the client.
+type ResponseHandler interface {
+	HandleResponse(ctx *Context) error
+}
+
+// WebSocketHandler runs for every text or binary message relayed on a
+// WebSocket connection, in both directions and in registration order.
+// Returning ErrDrop drops the message but keeps the connection; any other
+// error closes it.
+type WebSocketHandler interface {
+	HandleWebSocketMessage(msg *WebSocketMessage) error
+}
+
+// WebSocketMessage is one complete WebSocket message, reassembled from its
+// fragments. Handlers edit Data and Binary to change what is forwarded.
+type WebSocketMessage struct {
+	// Context is the exchange of the handshake request; its RequestID
+	// links the message to the stored request.
+	Context    *Context
+	FromClient bool
+	Binary     bool
+	Data       []byte
+	Time       time.Time
+}
+
+// StreamHandler follows streamed bodies (see Context.Streamed and
+// Context.RequestStreamed) while they are forwarded. fromClient tells the
+// request body from the response body.
+//
+// The two directions of a call run concurrently: handlers keep their
+// state per direction, and only add to Context.Values in HandleRequest.
+type StreamHandler interface {
+	// HandleStreamData runs for every piece of a body before it is
+	// forwarded. An error ends the stream: a response error closes the
+	// client connection, a request error aborts the upstream request.
+	HandleStreamData(ctx *Context, fromClient bool, data []byte) error
+	// HandleStreamEnd runs once per body, after it ended or was cut
+	// short with err. Response trailers are available by then.
+	HandleStreamEnd(ctx *Context, fromClient bool, err error)
+}
+
+type RequestHandlerFunc func(ctx *Context) error
+
+func (f RequestHandlerFunc) HandleRequest(ctx *Context) error { return f(ctx) }
+
+type ResponseHandlerFunc func(ctx *Context) error
+
+func (f ResponseHandlerFunc) HandleResponse(ctx *Context) error { return f(ctx) }
+
+type WebSocketHandlerFunc func(msg *WebSocketMessage) error
+
+func (f WebSocketHandlerFunc) HandleWebSocketMessage(msg *WebSocketMessage) error { return f(msg) }
+
+// NewResponse builds a response to req that handlers can put in
+// Context.Response, with body stored in Context.ResponseBody.
+func NewResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
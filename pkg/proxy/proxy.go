@@ -0,0 +1,285 @@
+// Package proxy implements the intercepting proxy. It can be embedded in
+// other programs:
+//
+//	p := proxy.New(proxy.Options{})
+//	p.UseRequest(proxy.RequestHandlerFunc(func(ctx *proxy.Context) error {
+//		ctx.Request.Header.Set("X-Test", "1")
+//		return nil
+//	}))
+//	go p.ListenAndServe("127.0.0.1:0")
+package proxy
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"MITM_PROXY/pkg/cert"
+)
+
+// Options configures a Proxy.
+type Options struct {
+	// Transport forwards requests upstream. Defaults to a clone of
+	// http.DefaultTransport using UpstreamTLS and DialTLS.
+	Transport http.RoundTripper
+
+	// UpstreamTLS configures TLS connections to upstream servers. Nil
+	// means Go's defaults, which verify certificates against system roots.
+	UpstreamTLS *tls.Config
+
+	// DialTLS, if set, opens TLS connections to upstream servers in place
+	// of crypto/tls, e.g. to shape the ClientHello. clientHello is the raw
+	// ClientHello record of the client whose traffic is forwarded, or nil.
+	// The server certificate and the negotiated protocol are read from the
+	// returned connection if it has a ConnectionState() tls.ConnectionState
+	// method; connections that negotiated h2 are spoken to in HTTP/2.
+	// DialTLS must not offer h2 when cfg.NextProtos leaves it out.
+	DialTLS func(ctx context.Context, addr string, cfg *tls.Config, clientHello []byte) (net.Conn, error)
+
+	// CACert and CAKey sign the certificates forged for HTTPS interception.
+	// When nil the CA loaded with cert.LoadCA is used; without any CA,
+	// HTTPS connections are tunnelled untouched.
+	CACert *x509.Certificate
+	CAKey  *rsa.PrivateKey
+
+	// WrapTunnel, if set, wraps the upstream side of connections that are
+	// relayed blindly: HTTPS without interception and protocol upgrades.
+	WrapTunnel func(upstream io.ReadWriteCloser, host string) io.ReadWriteCloser
+
+	// Block, if set, is asked before contacting u: the CONNECT target of
+	// HTTPS tunnels, with tunnel set, and every forwarded request. Blocked
+	// clients get status, or a connection reset when status is 0.
+	Block func(u *url.URL, tunnel bool) (status int, blocked bool)
+
+	// PassThrough, if set, selects HTTPS hosts that are tunnelled without
+	// interception. host is the SNI of the client's ClientHello, or the
+	// CONNECT host when it has none.
+	PassThrough func(host string) bool
+
+	// OnHandshake, if set, is told the outcome of every TLS handshake with
+	// a client, for the same host as PassThrough; err is nil on success.
+	OnHandshake func(host string, err error)
+
+	// OnTunnel, if set, receives a record of every connection relayed
+	// without interception once it closes.
+	OnTunnel func(t Tunnel)
+
+	// OnTLSConnection, if set, is told about every intercepted TLS
+	// connection right after the handshake, before its first request.
+	OnTLSConnection func(info *TLSInfo)
+
+	// StreamCaptureLimit caps how much of a streamed response body is kept
+	// in Context.ResponseBody. Zero means 10 MB.
+	StreamCaptureLimit int
+}
+
+// Tunnel describes a connection relayed without interception.
+type Tunnel struct {
+	Host      string // CONNECT target
+	SNI       string // server name from the ClientHello, if any
+	Reason    string // pass-through or no-ca
+	Start     time.Time
+	Duration  time.Duration
+	BytesUp   int64 // client to server
+	BytesDown int64 // server to client
+}
+
+// Proxy is an intercepting HTTP/HTTPS proxy. Register handlers with Use
+// before serving; a Proxy with no handlers simply forwards traffic.
+type Proxy struct {
+	opts             Options
+	transport        http.RoundTripper
+	requestHandlers  []RequestHandler
+	responseHandlers []ResponseHandler
+	wsHandlers       []WebSocketHandler
+	streamHandlers   []StreamHandler
+
+	certMu sync.Mutex
+	certs  map[string]cachedCert // forged certificates by SNI and target
+}
+
+// certCacheTTL bounds how long a forged certificate is reused, so that
+// changes to the upstream certificate are picked up.
+const certCacheTTL = time.Hour
+
+type cachedCert struct {
+	cert    *tls.Certificate
+	expires time.Time
+}
+
+func New(opts Options) *Proxy {
+	p := &Proxy{opts: opts, transport: opts.Transport}
+	if p.transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = opts.UpstreamTLS.Clone()
+		p.transport = t
+		if opts.DialTLS != nil {
+			p.transport = newDialTransport(p, t)
+		}
+	}
+	return p
+}
+
+// Use appends handlers to the chain. Each handler is registered for every
+// phase it implements: RequestHandler, ResponseHandler, WebSocketHandler
+// and StreamHandler. A value implementing none of them is an error, and
+// then none of handlers is registered. UseRequest and the like check their
+// handler at compile time instead.
+func (p *Proxy) Use(handlers ...any) error {
+	for _, h := range handlers {
+		switch h.(type) {
+		case RequestHandler, ResponseHandler, WebSocketHandler, StreamHandler:
+		default:
+			return fmt.Errorf("proxy: %T implements no handler interface", h)
+		}
+	}
+	for _, h := range handlers {
+		if rh, ok := h.(RequestHandler); ok {
+			p.UseRequest(rh)
+		}
+		if sh, ok := h.(ResponseHandler); ok {
+			p.UseResponse(sh)
+		}
+		if wh, ok := h.(WebSocketHandler); ok {
+			p.UseWebSocket(wh)
+		}
+		if th, ok := h.(StreamHandler); ok {
+			p.UseStream(th)
+		}
+	}
+	return nil
+}
+
+// UseRequest appends h to the request handlers.
+func (p *Proxy) UseRequest(h RequestHandler) {
+	p.requestHandlers = append(p.requestHandlers, h)
+}
+
+// UseResponse appends h to the response handlers.
+func (p *Proxy) UseResponse(h ResponseHandler) {
+	p.responseHandlers = append(p.responseHandlers, h)
+}
+
+// UseWebSocket appends h to the WebSocket message handlers.
+func (p *Proxy) UseWebSocket(h WebSocketHandler) {
+	p.wsHandlers = append(p.wsHandlers, h)
+}
+
+// UseStream appends h to the stream handlers.
+func (p *Proxy) UseStream(h StreamHandler) {
+	p.streamHandlers = append(p.streamHandlers, h)
+}
+
+// ListenAndServe listens on the TCP address addr and serves proxy clients.
+func (p *Proxy) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Println("Proxy listening on", ln.Addr())
+	return p.Serve(ln)
+}
+
+// Serve accepts connections on ln until it is closed.
+func (p *Proxy) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			log.Println("Accept error:", err)
+			continue
+		}
+		go p.HandleClient(conn)
+	}
+}
+
+func (p *Proxy) wrapTunnel(upstream io.ReadWriteCloser, host string) io.ReadWriteCloser {
+	if p.opts.WrapTunnel == nil {
+		return upstream
+	}
+	return p.opts.WrapTunnel(upstream, host)
+}
+
+// blocked reports whether Options.Block rejects u, after answering the
+// client on w or resetting conn.
+func (p *Proxy) blocked(conn net.Conn, w io.Writer, u *url.URL, tunnel bool) bool {
+	status, blocked := p.checkBlock(u, tunnel)
+	if !blocked {
+		return false
+	}
+	if status == 0 {
+		resetConn(conn)
+		return true
+	}
+	writeError(w, status, "blocked by proxy")
+	if f, ok := w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	return true
+}
+
+// checkBlock asks Options.Block about u.
+func (p *Proxy) checkBlock(u *url.URL, tunnel bool) (status int, blocked bool) {
+	if p.opts.Block == nil {
+		return 0, false
+	}
+	status, blocked = p.opts.Block(u, tunnel)
+	if blocked {
+		log.Printf("Blocked %s", u)
+	}
+	return status, blocked
+}
+
+// upstreamTLS returns the TLS configuration for dialing serverName.
+func (p *Proxy) upstreamTLS(serverName string) *tls.Config {
+	cfg := &tls.Config{}
+	if p.opts.UpstreamTLS != nil {
+		cfg = p.opts.UpstreamTLS.Clone()
+	}
+	cfg.ServerName = serverName
+	if cfg.NextProtos == nil && p.opts.Transport == nil {
+		// The default transport speaks HTTP/2 when the server agrees
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return cfg
+}
+
+// tlsInfoKey carries the client's *TLSInfo in upstream request contexts.
+type tlsInfoKey struct{}
+
+// dialTLS opens a TLS connection to hostPort for serverName on behalf of
+// the client described by info, which may be nil. h2 is not offered when
+// ctx is marked with http1OnlyKey.
+func (p *Proxy) dialTLS(ctx context.Context, hostPort, serverName string, info *TLSInfo) (net.Conn, error) {
+	cfg := p.upstreamTLS(serverName)
+	if ctx.Value(http1OnlyKey{}) != nil {
+		cfg.NextProtos = []string{"http/1.1"}
+	}
+	if p.opts.DialTLS == nil {
+		return (&tls.Dialer{Config: cfg}).DialContext(ctx, "tcp", hostPort)
+	}
+	var hello []byte
+	if info != nil {
+		hello = info.ClientHello
+	}
+	return p.opts.DialTLS(ctx, hostPort, cfg, hello)
+}
+
+func (p *Proxy) ca() (*x509.Certificate, *rsa.PrivateKey) {
+	if p.opts.CACert != nil && p.opts.CAKey != nil {
+		return p.opts.CACert, p.opts.CAKey
+	}
+	return cert.GetCA()
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testCA returns a throwaway CA for forging certificates.
+func testCA(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+// startProxy serves p on a loopback port for the duration of the test and
+// returns a client using it. Upstream certificates are not verified.
+func startProxy(t *testing.T, p *Proxy, h2 bool) *http.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go p.Serve(ln)
+
+	proxyURL := &url.URL{Scheme: "http", Host: ln.Addr().String()}
+	return &http.Client{Transport: &http.Transport{
+		Proxy:             http.ProxyURL(proxyURL),
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: h2,
+	}}
+}
+
+// testOptions intercepts HTTPS with a throwaway CA and trusts any
+// upstream certificate.
+func testOptions(t *testing.T) Options {
+	ca, key := testCA(t)
+	return Options{CACert: ca, CAKey: key, UpstreamTLS: &tls.Config{InsecureSkipVerify: true}}
+}
+
+func TestUse(t *testing.T) {
+	p := New(Options{})
+	req := RequestHandlerFunc(func(ctx *Context) error { return nil })
+	if err := p.Use(req, "not a handler"); err == nil {
+		t.Fatal("Use accepted a string")
+	}
+	if len(p.requestHandlers) != 0 {
+		t.Fatal("Use registered handlers despite the error")
+	}
+	if err := p.Use(req, Capture); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.requestHandlers) != 2 || len(p.responseHandlers) != 1 || len(p.wsHandlers) != 1 || len(p.streamHandlers) != 1 {
+		t.Errorf("registered %d request, %d response, %d WebSocket and %d stream handlers, want 2, 1, 1, 1",
+			len(p.requestHandlers), len(p.responseHandlers), len(p.wsHandlers), len(p.streamHandlers))
+	}
+}
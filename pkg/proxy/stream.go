@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"MITM_PROXY/pkg/grpc"
+	"MITM_PROXY/pkg/sse"
+)
+
+// defaultStreamCaptureLimit is used when Options.StreamCaptureLimit is 0.
+const defaultStreamCaptureLimit = 10 << 20
+
+// errStreamClosed ends streams whose body was closed before its end,
+// typically because the client went away.
+var errStreamClosed = errors.New("proxy: stream closed before its end")
+
+// streaming reports whether resp should be streamed to the client rather
+// than buffered: bodies of unknown length may never end or matter as they
+// come (chunked JSON streams, multipart/x-mixed-replace, long polls), and
+// bodies over the capture limit are not worth holding in memory. gRPC and
+// Server-Sent Events are always streamed, as they are parsed on the way.
+func (p *Proxy) streaming(resp *http.Response) bool {
+	if resp.StatusCode < 200 || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified ||
+		(resp.Request != nil && resp.Request.Method == http.MethodHead) {
+		return false
+	}
+	if resp.ContentLength < 0 || resp.ContentLength > int64(p.streamCaptureLimit()) {
+		return true
+	}
+	return grpc.IsGRPC(resp.Header) || sse.IsEventStream(resp.Header)
+}
+
+// streamingRequest reports whether the body of req should be forwarded as
+// the client sends it. Only gRPC calls need it, and they run over HTTP/2
+// only; an HTTP/1 connection could not carry the next request before the
+// body is read anyway.
+func streamingRequest(req *http.Request) bool {
+	return req.ProtoMajor == 2 && grpc.IsGRPC(req.Header)
+}
+
+// setStreamRequestBody makes the request forward the client body as it is
+// read, running the stream handlers.
+func (p *Proxy) setStreamRequestBody(ctx *Context, body io.ReadCloser) {
+	ctx.RequestBody = nil
+	ctx.Request.Body = &streamBody{p: p, ctx: ctx, body: body, fromClient: true}
+}
+
+// setStreamBody makes resp forward the upstream body as it is read,
+// capturing it into ctx and running the stream handlers.
+func (p *Proxy) setStreamBody(ctx *Context, body io.ReadCloser) {
+	ctx.ResponseBody = nil
+	resp := ctx.Response
+	resp.Body = &streamBody{p: p, ctx: ctx, body: body, limit: p.streamCaptureLimit()}
+	// Written to HTTP/1 clients as is; HTTP/2 responses are framed by
+	// serveHTTP2 regardless
+	if resp.ProtoMajor != 1 {
+		resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/1.1", 1, 1
+	}
+	if resp.ContentLength < 0 || len(resp.Trailer) > 0 {
+		resp.ContentLength = -1
+		resp.TransferEncoding = []string{"chunked"}
+	}
+}
+
+func (p *Proxy) streamCaptureLimit() int {
+	if p.opts.StreamCaptureLimit > 0 {
+		return p.opts.StreamCaptureLimit
+	}
+	return defaultStreamCaptureLimit
+}
+
+// streamBody runs the stream handlers on a body as it is forwarded. The
+// upstream body of a response is also captured into its Context.
+type streamBody struct {
+	p          *Proxy
+	ctx        *Context
+	body       io.ReadCloser
+	fromClient bool
+	limit      int
+	once       sync.Once
+}
+
+func (b *streamBody) Read(buf []byte) (int, error) {
+	n, err := b.body.Read(buf)
+	if n > 0 {
+		data := buf[:n]
+		if !b.fromClient {
+			b.capture(data)
+		}
+		for _, h := range b.p.streamHandlers {
+			if herr := h.HandleStreamData(b.ctx, b.fromClient, data); herr != nil {
+				b.end(herr)
+				return 0, herr
+			}
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		b.end(nil)
+	} else if err != nil {
+		b.end(err)
+	}
+	return n, err
+}
+
+// capture appends data to the captured response body, up to the limit.
+func (b *streamBody) capture(data []byte) {
+	ctx := b.ctx
+	if room := b.limit - len(ctx.ResponseBody); room < len(data) {
+		ctx.ResponseBody = append(ctx.ResponseBody, data[:max(room, 0)]...)
+		ctx.ResponseTruncated = true
+	} else {
+		ctx.ResponseBody = append(ctx.ResponseBody, data...)
+	}
+}
+
+func (b *streamBody) Close() error {
+	err := b.body.Close()
+	b.end(errStreamClosed)
+	return err
+}
+
+func (b *streamBody) end(err error) {
+	b.once.Do(func() {
+		for _, h := range b.p.streamHandlers {
+			h.HandleStreamEnd(b.ctx, b.fromClient, err)
+		}
+	})
+}
+
+// flushWriter flushes after every write so that streamed bodies reach the
+// client as they arrive.
+type flushWriter struct {
+	w     io.Writer
+	flush func()
+}
+
+func (f flushWriter) Write(b []byte) (int, error) {
+	n, err := f.w.Write(b)
+	f.flush()
+	return n, err
+}
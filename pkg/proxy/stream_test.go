@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestStreamChunked checks that a chunked response of an ordinary media
+// type reaches the client line by line, as an LLM token stream would.
+func TestStreamChunked(t *testing.T) {
+	next := make(chan struct{})
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		for _, line := range []string{`{"token":"a"}`, `{"token":"b"}`} {
+			w.Write([]byte(line + "\n"))
+			w.(http.Flusher).Flush()
+			<-next
+		}
+	}))
+	defer up.Close()
+
+	p := New(testOptions(t))
+	c := startProxy(t, p, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, up.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	for _, want := range []string{`{"token":"a"}`, `{"token":"b"}`} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read %s: %v", want, err)
+		}
+		if line != want+"\n" {
+			t.Fatalf("line = %q, want %q", line, want)
+		}
+		next <- struct{}{}
+	}
+}
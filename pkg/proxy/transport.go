@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// errNegotiatedH2 is returned by the HTTP/1 dialer of dialTransport when
+// a server that spoke HTTP/1.1 before picks h2.
+var errNegotiatedH2 = errors.New("proxy: upstream negotiated h2 on an HTTP/1 connection")
+
+// http1OnlyKey marks upstream request contexts whose connection must not
+// offer h2, such as protocol upgrades.
+type http1OnlyKey struct{}
+
+// dialTransport forwards requests over connections opened by
+// Options.DialTLS. http.Transport only speaks HTTP/2 over *tls.Conn, so
+// HTTPS connections that negotiate h2 are handed to http2.Transport
+// instead, and HTTP/1.1 ones to http.Transport.
+type dialTransport struct {
+	p  *Proxy
+	h1 *http.Transport
+	h2 *http2.Transport
+
+	mu      sync.Mutex
+	h2Conns map[string]*http2.ClientConn // by host:port
+	h1Hosts map[string]bool              // hosts that picked HTTP/1.1
+	spare   map[string][]net.Conn        // HTTP/1.1 connections not yet used by h1
+}
+
+func newDialTransport(p *Proxy, h1 *http.Transport) *dialTransport {
+	t := &dialTransport{
+		p:       p,
+		h1:      h1,
+		h2:      &http2.Transport{IdleConnTimeout: h1.IdleConnTimeout},
+		h2Conns: map[string]*http2.ClientConn{},
+		h1Hosts: map[string]bool{},
+		spare:   map[string][]net.Conn{},
+	}
+	h1.DialTLSContext = t.dialHTTP1
+	return t
+}
+
+func (t *dialTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.h1.RoundTrip(req)
+	}
+	if req.Header.Get("Upgrade") != "" {
+		// HTTP/2 has no Upgrade
+		return t.h1.RoundTrip(req.WithContext(context.WithValue(req.Context(), http1OnlyKey{}, true)))
+	}
+	addr := hostPort(req)
+
+	t.mu.Lock()
+	cc, h1 := t.h2Conns[addr], t.h1Hosts[addr]
+	t.mu.Unlock()
+	if cc != nil && cc.ReserveNewRequest() {
+		return cc.RoundTrip(req)
+	}
+	if h1 {
+		resp, err := t.h1.RoundTrip(req)
+		if !errors.Is(err, errNegotiatedH2) {
+			return resp, err
+		}
+	}
+
+	conn, err := t.dial(req.Context(), addr)
+	if err != nil {
+		return nil, err
+	}
+	if negotiated(conn) != "h2" {
+		t.mu.Lock()
+		t.h1Hosts[addr] = true
+		t.spare[addr] = append(t.spare[addr], conn)
+		t.mu.Unlock()
+		return t.h1.RoundTrip(req)
+	}
+	cc, err = t.h2.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	t.mu.Lock()
+	if old := t.h2Conns[addr]; old == nil || !old.CanTakeNewRequest() {
+		t.h2Conns[addr] = cc
+	}
+	delete(t.h1Hosts, addr)
+	t.mu.Unlock()
+	return cc.RoundTrip(req)
+}
+
+// dialHTTP1 is the DialTLSContext of the HTTP/1 transport. It takes a
+// spare connection if RoundTrip left one.
+func (t *dialTransport) dialHTTP1(ctx context.Context, network, addr string) (net.Conn, error) {
+	t.mu.Lock()
+	if conns := t.spare[addr]; len(conns) > 0 {
+		conn := conns[len(conns)-1]
+		t.spare[addr] = conns[:len(conns)-1]
+		t.mu.Unlock()
+		return conn, nil
+	}
+	t.mu.Unlock()
+
+	conn, err := t.dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if negotiated(conn) == "h2" {
+		conn.Close()
+		t.mu.Lock()
+		delete(t.h1Hosts, addr)
+		t.mu.Unlock()
+		return nil, errNegotiatedH2
+	}
+	return conn, nil
+}
+
+func (t *dialTransport) dial(ctx context.Context, addr string) (net.Conn, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	info, _ := ctx.Value(tlsInfoKey{}).(*TLSInfo)
+	return t.p.dialTLS(ctx, addr, host, info)
+}
+
+// hostPort returns the host:port req goes to.
+func hostPort(req *http.Request) string {
+	port := req.URL.Port()
+	if port == "" {
+		port = "443"
+	}
+	return net.JoinHostPort(req.URL.Hostname(), port)
+}
+
+// negotiated returns the ALPN protocol of conn, if it tells.
+func negotiated(conn net.Conn) string {
+	if c, ok := conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		return c.ConnectionState().NegotiatedProtocol
+	}
+	return ""
+}
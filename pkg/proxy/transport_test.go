@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// hiddenConn hides *tls.Conn from http.Transport, as connections from
+// uTLS are.
+type hiddenConn struct {
+	net.Conn
+	state tls.ConnectionState
+}
+
+func (c hiddenConn) ConnectionState() tls.ConnectionState { return c.state }
+
+func dialHidden(ctx context.Context, addr string, cfg *tls.Config, clientHello []byte) (net.Conn, error) {
+	conn, err := (&tls.Dialer{Config: cfg}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return hiddenConn{Conn: conn, state: conn.(*tls.Conn).ConnectionState()}, nil
+}
+
+// TestDialTLSProtocols checks that connections from Options.DialTLS carry
+// HTTP/2 when the server picks it, and HTTP/1.1 otherwise.
+func TestDialTLSProtocols(t *testing.T) {
+	for _, h2 := range []bool{true, false} {
+		up := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto)
+		}))
+		up.EnableHTTP2 = h2
+		up.StartTLS()
+		defer up.Close()
+
+		want := map[bool]string{true: "HTTP/2.0", false: "HTTP/1.1"}[h2]
+		t.Run(want, func(t *testing.T) {
+			opts := testOptions(t)
+			opts.DialTLS = dialHidden
+			c := startProxy(t, New(opts), false)
+			for range 3 {
+				resp, err := c.Get(up.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(got) != want {
+					t.Errorf("upstream saw %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2).
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+)
+
+// maxWebSocketMessage bounds a reassembled message; larger ones close the
+// connection.
+const maxWebSocketMessage = 16 << 20
+
+// isWebSocket reports whether h asks for or accepts an upgrade to
+// WebSocket.
+func isWebSocket(h http.Header) bool {
+	for _, v := range h.Values("Upgrade") {
+		for _, p := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(p), "websocket") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type wsFrame struct {
+	fin     bool
+	rsv     byte // RSV1-3 bits, in place
+	opcode  byte
+	payload []byte
+}
+
+// readFrame reads one frame and unmasks its payload.
+func readFrame(r io.Reader) (wsFrame, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return wsFrame{}, err
+	}
+	f := wsFrame{
+		fin:    hdr[0]&0x80 != 0,
+		rsv:    hdr[0] & 0x70,
+		opcode: hdr[0] & 0x0f,
+	}
+	masked := hdr[1]&0x80 != 0
+
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return f, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return f, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxWebSocketMessage {
+		return f, fmt.Errorf("websocket: %d byte frame exceeds limit", n)
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return f, err
+	}
+	if masked {
+		for i := range f.payload {
+			f.payload[i] ^= key[i%4]
+		}
+	}
+	return f, nil
+}
+
+// writeFrame writes f in a single Write, masked with a fresh key when mask
+// is set, as frames from clients must be.
+func writeFrame(w io.Writer, f wsFrame, mask bool) error {
+	b0 := f.rsv | f.opcode
+	if f.fin {
+		b0 |= 0x80
+	}
+	buf := []byte{b0, 0}
+	n := len(f.payload)
+	switch {
+	case n < 126:
+		buf[1] = byte(n)
+	case n <= 0xffff:
+		buf[1] = 126
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf[1] = 127
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+
+	if !mask {
+		buf = append(buf, f.payload...)
+	} else {
+		buf[1] |= 0x80
+		var key [4]byte
+		rand.Read(key[:])
+		buf = append(buf, key[:]...)
+		for i, c := range f.payload {
+			buf = append(buf, c^key[i%4])
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// relayWebSocket relays an upgraded WebSocket connection until either side
+// closes it, running the WebSocket handlers on every message. The caller
+// closes the client connection, which ends the client side.
+func (p *Proxy) relayWebSocket(ctx *Context, client io.Writer, clientReader *bufio.Reader, upstream io.ReadWriteCloser) {
+	go func() {
+		if err := p.relayMessages(ctx, clientReader, upstream, true); !closed(err) {
+			log.Printf("WebSocket client side of #%d: %v", ctx.RequestID, err)
+		}
+		// Unblocks the server side
+		upstream.Close()
+	}()
+	if err := p.relayMessages(ctx, bufio.NewReader(upstream), client, false); !closed(err) {
+		log.Printf("WebSocket server side of #%d: %v", ctx.RequestID, err)
+	}
+}
+
+// closed reports whether err is just the end of the connection.
+func closed(err error) bool {
+	return err == nil || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)
+}
+
+// relayMessages copies frames from src to dst. Data frames are reassembled
+// into messages for the handlers and forwarded unfragmented; control
+// frames, which may come between fragments, are forwarded right away.
+// Frames using an extension (RSV bits set) are forwarded untouched.
+func (p *Proxy) relayMessages(ctx *Context, src io.Reader, dst io.Writer, fromClient bool) error {
+	var (
+		opcode byte
+		data   []byte
+	)
+	for {
+		f, err := readFrame(src)
+		if err != nil {
+			return err
+		}
+		if f.opcode >= wsClose || f.rsv != 0 || (f.opcode != wsContinuation && f.opcode != wsText && f.opcode != wsBinary) {
+			if err := writeFrame(dst, f, fromClient); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if f.opcode != wsContinuation {
+			opcode, data = f.opcode, nil
+		}
+		if len(data)+len(f.payload) > maxWebSocketMessage {
+			return fmt.Errorf("websocket: message exceeds %d bytes", maxWebSocketMessage)
+		}
+		data = append(data, f.payload...)
+		if !f.fin {
+			continue
+		}
+
+		msg := &WebSocketMessage{
+			Context:    ctx,
+			FromClient: fromClient,
+			Binary:     opcode == wsBinary,
+			Data:       data,
+			Time:       time.Now(),
+		}
+		data = nil
+		if err := p.handleWebSocketMessage(msg); err != nil {
+			if errors.Is(err, ErrDrop) {
+				continue
+			}
+			return err
+		}
+		out := wsFrame{fin: true, opcode: wsText, payload: msg.Data}
+		if msg.Binary {
+			out.opcode = wsBinary
+		}
+		if err := writeFrame(dst, out, fromClient); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Proxy) handleWebSocketMessage(msg *WebSocketMessage) error {
+	for _, h := range p.wsHandlers {
+		if err := h.HandleWebSocketMessage(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 5, 125, 126, 0xffff, 0x10000} {
+		for _, mask := range []bool{false, true} {
+			f := wsFrame{fin: n%2 == 0, rsv: 0x40, opcode: wsBinary, payload: bytes.Repeat([]byte{'x'}, n)}
+			var buf bytes.Buffer
+			if err := writeFrame(&buf, f, mask); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.Bytes()[1]&0x80 != 0; got != mask {
+				t.Errorf("%d bytes: mask bit = %v, want %v", n, got, mask)
+			}
+			got, err := readFrame(&buf)
+			if err != nil {
+				t.Fatalf("%d bytes, mask %v: %v", n, mask, err)
+			}
+			if !reflect.DeepEqual(got, f) {
+				t.Errorf("%d bytes, mask %v: read fin=%v rsv=%#x opcode=%d len=%d", n, mask, got.fin, got.rsv, got.opcode, len(got.payload))
+			}
+			if buf.Len() != 0 {
+				t.Errorf("%d bytes, mask %v: %d bytes left unread", n, mask, buf.Len())
+			}
+		}
+	}
+}
+
+// TestReadFrame reads the examples of RFC 6455 section 5.7.
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  wsFrame
+	}{
+		{"unmasked text", []byte{0x81, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f}, wsFrame{fin: true, opcode: wsText, payload: []byte("Hello")}},
+		{"masked text", []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58}, wsFrame{fin: true, opcode: wsText, payload: []byte("Hello")}},
+		{"first fragment", []byte{0x01, 0x03, 0x48, 0x65, 0x6c}, wsFrame{opcode: wsText, payload: []byte("Hel")}},
+		{"last fragment", []byte{0x80, 0x02, 0x6c, 0x6f}, wsFrame{fin: true, opcode: wsContinuation, payload: []byte("lo")}},
+		{"ping", []byte{0x89, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f}, wsFrame{fin: true, opcode: 0x9, payload: []byte("Hello")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFrame(bytes.NewReader(tt.frame))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frame = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	huge := []byte{0x82, 127, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(huge[2:], maxWebSocketMessage+1)
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"empty", nil},
+		{"short header", []byte{0x81}},
+		{"short length", []byte{0x81, 126, 0}},
+		{"short mask key", []byte{0x81, 0x85, 0x37}},
+		{"short payload", []byte{0x81, 0x05, 0x48}},
+		{"over the limit", huge},
+	}
+	for _, tt := range tests {
+		if _, err := readFrame(bytes.NewReader(tt.frame)); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
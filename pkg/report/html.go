@@ -0,0 +1,81 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+type htmlFinding struct {
+	storage.Finding
+	Request  string
+	Response string
+}
+
+type htmlReport struct {
+	*Report
+	Severities []string
+	Items      []htmlFinding
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>MITM_PROXY findings report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table.summary td, table.summary th { padding: 4px 12px; text-align: left; }
+.finding { border: 1px solid #ccc; border-radius: 4px; margin: 1em 0; padding: 0.5em 1em; }
+.sev { display: inline-block; padding: 2px 8px; border-radius: 3px; color: #fff; text-transform: uppercase; font-size: 0.8em; }
+.sev-critical { background: #7b1fa2; } .sev-high { background: #d32f2f; } .sev-medium { background: #f57c00; }
+.sev-low { background: #fbc02d; color: #000; } .sev-info { background: #1976d2; }
+pre { background: #f5f5f5; padding: 0.5em; overflow-x: auto; white-space: pre-wrap; }
+dt { font-weight: bold; } dd { margin: 0 0 0.5em 1em; }
+</style>
+</head>
+<body>
+<h1>Findings report</h1>
+<p>Generated {{date .GeneratedAt}}{{if .Host}} for host <b>{{.Host}}</b>{{end}}{{if .MinSeverity}}, severity {{.MinSeverity}} and above{{end}}.</p>
+<table class="summary">
+<tr>{{range .Severities}}<th>{{.}}</th>{{end}}</tr>
+<tr>{{$s := .Summary}}{{range .Severities}}<td>{{index $s .}}</td>{{end}}</tr>
+</table>
+{{range .Items}}
+<div class="finding">
+<h3><span class="sev sev-{{.Severity}}">{{.Severity}}</span> #{{.ID}} {{.Type}}</h3>
+<dl>
+<dt>Location</dt><dd>{{.Host}}{{.Path}}{{if .Parameter}} &mdash; parameter <code>{{.Parameter}}</code>{{end}}</dd>
+<dt>Detail</dt><dd>{{.Detail}}</dd>
+<dt>Confidence / status</dt><dd>{{.Confidence}} / {{.Status}}</dd>
+<dt>Seen</dt><dd>{{.Occurrences}} time(s), first {{date .FirstSeen}}, last {{date .LastSeen}}</dd>
+{{if .Evidence}}<dt>Evidence</dt><dd><pre>{{.Evidence}}</pre></dd>{{end}}
+</dl>
+{{if .Request}}<h4>Request #{{.RequestID}}</h4><pre>{{.Request}}</pre>{{end}}
+{{if .Response}}<h4>Response #{{.ResponseID}}</h4><pre>{{.Response}}</pre>{{end}}
+</div>
+{{else}}
+<p>No findings.</p>
+{{end}}
+</body>
+</html>
+`))
+
+func writeHTML(w io.Writer, rep *Report) error {
+	data := htmlReport{Report: rep}
+	for i := len(storage.Severities) - 1; i >= 0; i-- {
+		data.Severities = append(data.Severities, storage.Severities[i])
+	}
+	for _, f := range rep.Findings {
+		data.Items = append(data.Items, htmlFinding{
+			Finding:  f,
+			Request:  requestEvidence(f.RequestID),
+			Response: responseEvidence(f.ResponseID),
+		})
+	}
+	return htmlTemplate.Execute(w, data)
+}
@@ -0,0 +1,135 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// Formats lists the supported report formats.
+var Formats = []string{"html", "json", "sarif"}
+
+// maxEvidence caps the request/response text embedded per finding.
+const maxEvidence = 8 << 10
+
+type Report struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Host        string            `json:"host,omitempty"`
+	MinSeverity string            `json:"min_severity,omitempty"`
+	Summary     map[string]int    `json:"summary"`
+	Findings    []storage.Finding `json:"findings"`
+}
+
+func ContentType(format string) string {
+	switch format {
+	case "html":
+		return "text/html; charset=utf-8"
+	case "sarif":
+		return "application/sarif+json"
+	}
+	return "application/json"
+}
+
+// Generate writes findings matching filter to w in the given format.
+// Unless a status is requested explicitly, false positives are left out.
+func Generate(w io.Writer, format string, filter storage.FindingFilter) error {
+	findings, err := storage.ListFindings(filter)
+	if err != nil {
+		return err
+	}
+	if filter.Status == "" {
+		kept := findings[:0]
+		for _, f := range findings {
+			if f.Status != storage.StatusFalsePositive {
+				kept = append(kept, f)
+			}
+		}
+		findings = kept
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return storage.SeverityRank(findings[i].Severity) > storage.SeverityRank(findings[j].Severity)
+	})
+
+	rep := &Report{
+		GeneratedAt: time.Now(),
+		Host:        filter.Host,
+		MinSeverity: filter.MinSeverity,
+		Summary:     map[string]int{},
+		Findings:    findings,
+	}
+	for _, f := range findings {
+		rep.Summary[f.Severity]++
+	}
+
+	switch format {
+	case "html":
+		return writeHTML(w, rep)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	case "sarif":
+		return writeSARIF(w, rep)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+// requestEvidence renders stored request #id roughly as it went on the wire.
+func requestEvidence(id int) string {
+	if id == 0 {
+		return ""
+	}
+	req, err := storage.GetRequestByID(id)
+	if err != nil {
+		return ""
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s\n", req.Method, req.URL.String())
+	req.Header.Write(&b)
+	b.WriteString("\n")
+	io.Copy(&b, req.Body)
+	return truncate(b.String())
+}
+
+func responseEvidence(id int) string {
+	if id == 0 {
+		return ""
+	}
+	resp, err := storage.GetResponseByID(id)
+	if err != nil {
+		return ""
+	}
+	var header http.Header
+	json.Unmarshal(resp.Headers, &header)
+
+	var b bytes.Buffer
+	status := resp.StatusMessage
+	if !strings.HasPrefix(status, fmt.Sprint(resp.StatusCode)) {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, status)
+	}
+	fmt.Fprintf(&b, "%s\n", status)
+	header.Write(&b)
+	b.WriteString("\n")
+	b.WriteString(resp.Body)
+	return truncate(b.String())
+}
+
+// truncate cuts s to at most maxEvidence bytes, on a rune boundary.
+func truncate(s string) string {
+	if len(s) <= maxEvidence {
+		return s
+	}
+	n := maxEvidence
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "\n... (truncated)"
+}
@@ -0,0 +1,26 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, s := range []string{
+		strings.Repeat("a", maxEvidence+10),
+		"a" + strings.Repeat("я", maxEvidence),
+		strings.Repeat("€", maxEvidence),
+	} {
+		got := truncate(s)
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate split a rune: ...%q", got[len(got)-20:])
+		}
+		if body, _, _ := strings.Cut(got, "\n... (truncated)"); len(body) > maxEvidence || len(body) < maxEvidence-3 {
+			t.Errorf("kept %d bytes, want at most %d", len(body), maxEvidence)
+		}
+	}
+	if got := truncate("short"); got != "short" {
+		t.Errorf("truncate(short) = %q", got)
+	}
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// Minimal subset of SARIF 2.1.0 needed to publish findings to CI tooling.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]any    `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case "critical", "high":
+		return "error"
+	case "medium":
+		return "warning"
+	}
+	return "note"
+}
+
+func writeSARIF(w io.Writer, rep *Report) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "MITM_PROXY",
+			InformationURI: "https://github.com/supchaser/MITM_Proxy",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	seenRules := map[string]bool{}
+	for _, f := range rep.Findings {
+		if !seenRules[f.Type] {
+			seenRules[f.Type] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               f.Type,
+				ShortDescription: sarifMessage{Text: f.Detail},
+			})
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  f.Type,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: f.Detail},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: findingURI(f)},
+				},
+			}},
+			PartialFingerprints: map[string]string{"findingKey/v1": f.DedupKey()},
+			Properties: map[string]any{
+				"id":         f.ID,
+				"severity":   f.Severity,
+				"confidence": f.Confidence,
+				"parameter":  f.Parameter,
+				"status":     f.Status,
+				"evidence":   f.Evidence,
+			},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+func findingURI(f storage.Finding) string {
+	u := url.URL{Scheme: "https", Host: f.Host, Path: f.Path}
+	if req, err := storage.GetRequestByID(f.RequestID); err == nil && req.URL.Scheme != "" {
+		u.Scheme = req.URL.Scheme
+	}
+	return u.String()
+}
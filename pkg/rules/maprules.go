@@ -0,0 +1,165 @@
+package rules
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+const (
+	MapLocal  = "local"
+	MapRemote = "remote"
+)
+
+// MappedResponse is a map-local answer to be served instead of upstream.
+type MappedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type compiledMap struct {
+	storage.MapRule
+	scope  match.Scope
+	target *url.URL
+}
+
+var (
+	mapMu     sync.RWMutex
+	activeMap []compiledMap
+)
+
+func compileMap(r storage.MapRule) (compiledMap, error) {
+	c := compiledMap{MapRule: r}
+	switch r.Kind {
+	case MapLocal:
+		if r.LocalPath == "" {
+			return c, fmt.Errorf("map-local needs local_path")
+		}
+		if r.Status != 0 && (r.Status < 100 || r.Status > 999) {
+			return c, fmt.Errorf("bad status %d", r.Status)
+		}
+	case MapRemote:
+		u, err := url.Parse(r.Target)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return c, fmt.Errorf("map-remote target must look like https://host[:port]")
+		}
+		c.target = u
+	default:
+		return c, fmt.Errorf("kind must be %q or %q", MapLocal, MapRemote)
+	}
+
+	scope, err := match.NewScope(r.Host, r.Path, r.Method)
+	if err != nil {
+		return c, err
+	}
+	c.scope = scope
+	return c, nil
+}
+
+// ValidateMap checks that r can be compiled.
+func ValidateMap(r storage.MapRule) error {
+	_, err := compileMap(r)
+	return err
+}
+
+func loadMaps() error {
+	stored, err := storage.ListMapRules()
+	if err != nil {
+		return err
+	}
+	var next []compiledMap
+	for _, r := range stored {
+		if !r.Enabled {
+			continue
+		}
+		c, err := compileMap(r)
+		if err != nil {
+			log.Printf("Skipping map rule %d: %v", r.ID, err)
+			continue
+		}
+		next = append(next, c)
+	}
+
+	mapMu.Lock()
+	activeMap = next
+	mapMu.Unlock()
+	return nil
+}
+
+// MapRequest applies the first map rule matching req. A map-local rule
+// yields the response to serve; a map-remote rule retargets req in place
+// and yields nil.
+func MapRequest(req *http.Request) *MappedResponse {
+	mapMu.RLock()
+	var rule *compiledMap
+	for i := range activeMap {
+		if activeMap[i].scope.Matches(req) {
+			rule = &activeMap[i]
+			break
+		}
+	}
+	mapMu.RUnlock()
+	if rule == nil {
+		return nil
+	}
+
+	if rule.Kind == MapRemote {
+		req.URL.Scheme = rule.target.Scheme
+		req.URL.Host = rule.target.Host
+		req.Host = rule.target.Host
+		return nil
+	}
+	return serveLocal(rule, req)
+}
+
+func serveLocal(rule *compiledMap, req *http.Request) *MappedResponse {
+	file := rule.LocalPath
+	if info, err := os.Stat(file); err == nil && info.IsDir() {
+		// Serve the part of the URL path after the rule's path match
+		rest := req.URL.Path
+		if rule.scope.Path != nil {
+			if loc := rule.scope.Path.FindStringIndex(rest); loc != nil {
+				rest = rest[loc[1]:]
+			}
+		}
+		file = filepath.Join(rule.LocalPath, filepath.FromSlash(filepath.Clean("/"+rest)))
+		if info, err := os.Stat(file); err == nil && info.IsDir() {
+			file = filepath.Join(file, "index.html")
+		}
+	}
+
+	body, err := os.ReadFile(file)
+	if err != nil {
+		log.Printf("Map rule %d: %v", rule.ID, err)
+		return &MappedResponse{
+			Status: http.StatusNotFound,
+			Header: http.Header{"Content-Type": {"text/plain"}},
+			Body:   []byte("map-local: " + filepath.Base(file) + " not found"),
+		}
+	}
+
+	header := http.Header{}
+	ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(file)))
+	if ct == "" {
+		ct = http.DetectContentType(body)
+	}
+	header.Set("Content-Type", ct)
+	for k, v := range rule.Headers {
+		header.Set(k, v)
+	}
+	status := rule.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &MappedResponse{Status: status, Header: header, Body: body}
+}
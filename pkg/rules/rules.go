@@ -0,0 +1,179 @@
+// Package rules applies match-and-replace rewrite rules and map-local /
+// map-remote rules to traffic passing through the proxy.
+package rules
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+const (
+	ActionAddHeader     = "add_header"
+	ActionRemoveHeader  = "remove_header"
+	ActionReplaceHeader = "replace_header"
+	ActionRewriteURL    = "rewrite_url"
+	ActionReplaceBody   = "replace_body"
+)
+
+type compiled struct {
+	storage.RewriteRule
+	scope match.Scope
+	re    *regexp.Regexp
+}
+
+var (
+	mu     sync.RWMutex
+	active []compiled
+)
+
+func compile(r storage.RewriteRule) (compiled, error) {
+	c := compiled{RewriteRule: r}
+	if r.Phase != "request" && r.Phase != "response" {
+		return c, fmt.Errorf("phase must be request or response")
+	}
+	switch r.Action {
+	case ActionAddHeader, ActionRemoveHeader, ActionReplaceHeader:
+		if r.Header == "" {
+			return c, fmt.Errorf("%s needs a header name", r.Action)
+		}
+	case ActionRewriteURL:
+		if r.Phase != "request" {
+			return c, fmt.Errorf("%s only applies to requests", r.Action)
+		}
+		if r.Match == "" {
+			return c, fmt.Errorf("%s needs a match pattern", r.Action)
+		}
+	case ActionReplaceBody:
+		if r.Match == "" {
+			return c, fmt.Errorf("%s needs a match pattern", r.Action)
+		}
+	default:
+		return c, fmt.Errorf("unknown action %q", r.Action)
+	}
+
+	scope, err := match.NewScope(r.Host, r.Path, r.Method)
+	if err != nil {
+		return c, err
+	}
+	c.scope = scope
+	if r.Match != "" {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return c, fmt.Errorf("bad match pattern: %w", err)
+		}
+		c.re = re
+	}
+	return c, nil
+}
+
+// Validate checks that r can be compiled.
+func Validate(r storage.RewriteRule) error {
+	_, err := compile(r)
+	return err
+}
+
+// Load replaces the active rewrite and map rules with the enabled rules
+// from storage.
+func Load() error {
+	if err := loadRewrites(); err != nil {
+		return err
+	}
+	return loadMaps()
+}
+
+func loadRewrites() error {
+	stored, err := storage.ListRewriteRules()
+	if err != nil {
+		return err
+	}
+	var next []compiled
+	for _, r := range stored {
+		if !r.Enabled {
+			continue
+		}
+		c, err := compile(r)
+		if err != nil {
+			log.Printf("Skipping rewrite rule %d: %v", r.ID, err)
+			continue
+		}
+		next = append(next, c)
+	}
+
+	mu.Lock()
+	active = next
+	mu.Unlock()
+	return nil
+}
+
+func matching(phase string, req *http.Request) []compiled {
+	mu.RLock()
+	defer mu.RUnlock()
+	var out []compiled
+	for _, c := range active {
+		if c.Phase == phase && c.scope.Matches(req) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ApplyRequest rewrites req in place and returns the new body.
+func ApplyRequest(req *http.Request, body []byte) []byte {
+	for _, c := range matching("request", req) {
+		switch c.Action {
+		case ActionRewriteURL:
+			u, err := url.Parse(c.re.ReplaceAllString(req.URL.String(), c.Value))
+			if err != nil || u.Host == "" {
+				log.Printf("Rewrite rule %d produced a bad URL: %v", c.ID, err)
+				continue
+			}
+			req.URL = u
+			req.Host = u.Host
+		case ActionReplaceBody:
+			body = c.re.ReplaceAll(body, []byte(c.Value))
+		default:
+			applyHeader(c, req.Header)
+		}
+	}
+	return body
+}
+
+// ApplyResponse rewrites resp in place and returns the new body. Bodies
+// are decoded before the first body replacement.
+func ApplyResponse(req *http.Request, resp *http.Response, body []byte) []byte {
+	for _, c := range matching("response", req) {
+		switch c.Action {
+		case ActionReplaceBody:
+			body = storage.DecodeResponseBody(resp, body)
+			body = c.re.ReplaceAll(body, []byte(c.Value))
+		default:
+			applyHeader(c, resp.Header)
+		}
+	}
+	return body
+}
+
+func applyHeader(c compiled, h http.Header) {
+	switch c.Action {
+	case ActionAddHeader:
+		h.Add(c.Header, c.Value)
+	case ActionRemoveHeader:
+		h.Del(c.Header)
+	case ActionReplaceHeader:
+		if c.re == nil {
+			h.Set(c.Header, c.Value)
+			return
+		}
+		values := h.Values(c.Header)
+		for i, v := range values {
+			values[i] = c.re.ReplaceAllString(v, c.Value)
+		}
+	}
+}
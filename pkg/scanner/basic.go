@@ -0,0 +1,79 @@
+package scanner
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+type BasicResult struct {
+	Issues   []string          `json:"issues"`
+	Findings []storage.Finding `json:"findings"`
+}
+
+// ScanBasic looks for suspicious patterns in a stored request without
+// sending any traffic.
+func ScanBasic(req *http.Request) *BasicResult {
+	result := &BasicResult{Issues: []string{}, Findings: []storage.Finding{}}
+
+	// Check for SQL injection patterns in URL parameters
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			v := strings.ToLower(value)
+			if strings.Contains(v, "select ") ||
+				strings.Contains(v, "insert ") ||
+				strings.Contains(v, "update ") ||
+				strings.Contains(v, "delete ") {
+				result.Issues = append(result.Issues, "Possible SQL injection in URL parameters")
+				result.Findings = append(result.Findings, storage.Finding{
+					Type:       "sql-injection-pattern",
+					Severity:   "medium",
+					Confidence: "tentative",
+					Parameter:  name,
+					Detail:     "Possible SQL injection in URL parameters",
+					Evidence:   value,
+				})
+				break
+			}
+		}
+	}
+
+	// Check for XSS patterns
+	bodyBytes, err := io.ReadAll(req.Body)
+	if err == nil {
+		req.Body = io.NopCloser(bytes.NewReader(bodyBytes)) // Reset body reader
+		bodyStr := string(bodyBytes)
+		if strings.Contains(bodyStr, "<script>") || strings.Contains(bodyStr, "javascript:") {
+			result.Issues = append(result.Issues, "Possible XSS in request body")
+			result.Findings = append(result.Findings, storage.Finding{
+				Type:       "xss-pattern",
+				Severity:   "medium",
+				Confidence: "tentative",
+				Detail:     "Possible XSS in request body",
+			})
+		}
+	}
+
+	// Check for sensitive headers
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if req.Header.Get(name) != "" {
+			result.Issues = append(result.Issues, "Request contains sensitive headers")
+			result.Findings = append(result.Findings, storage.Finding{
+				Type:       "sensitive-header",
+				Severity:   "info",
+				Confidence: "certain",
+				Parameter:  name,
+				Detail:     "Request contains sensitive headers",
+			})
+			break
+		}
+	}
+
+	if len(result.Issues) == 0 {
+		result.Issues = append(result.Issues, "No obvious security issues found")
+	}
+	return result
+}
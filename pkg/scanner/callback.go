@@ -0,0 +1,133 @@
+package scanner
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallbackHit is a single out-of-band request received by the callback listener.
+type CallbackHit struct {
+	Token      string    `json:"token"`
+	Path       string    `json:"path"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent"`
+	Time       time.Time `json:"time"`
+}
+
+var (
+	// callbackHost is the host:port targets are told to call back to, or
+	// empty when they can't reach the listener.
+	callbackMu   sync.RWMutex
+	callbackHost string
+
+	hitsMu sync.Mutex
+	hits   = map[string][]CallbackHit{}
+)
+
+// StartCallbackServer starts the out-of-band listener used by the SSRF
+// scanner and returns once it listens, so call it before scans can run.
+// publicHost is the address injected into payloads and must be reachable
+// from the scanned servers; callback payloads are skipped when it is
+// empty, when the listener can't start, or after it stops.
+func StartCallbackServer(addr, publicHost string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	setCallbackHost(publicHost)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ssrf/", handleCallback)
+	mux.HandleFunc("/meta/", handleMetadata)
+
+	log.Println("SSRF callback listener on", addr)
+	go func() {
+		err := http.Serve(ln, mux)
+		setCallbackHost("")
+		log.Println("SSRF callback listener stopped:", err)
+	}()
+	return nil
+}
+
+func setCallbackHost(host string) {
+	callbackMu.Lock()
+	callbackHost = host
+	callbackMu.Unlock()
+}
+
+func getCallbackHost() string {
+	callbackMu.RLock()
+	defer callbackMu.RUnlock()
+	return callbackHost
+}
+
+func newToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// expect registers token so that callbacks carrying it are recorded.
+func expect(token string) {
+	hitsMu.Lock()
+	hits[token] = []CallbackHit{}
+	hitsMu.Unlock()
+}
+
+// takeHits returns and forgets all callbacks recorded for token.
+func takeHits(token string) []CallbackHit {
+	hitsMu.Lock()
+	defer hitsMu.Unlock()
+	h := hits[token]
+	delete(hits, token)
+	return h
+}
+
+func recordHit(token string, r *http.Request) {
+	hitsMu.Lock()
+	defer hitsMu.Unlock()
+	if _, ok := hits[token]; !ok {
+		return
+	}
+	hits[token] = append(hits[token], CallbackHit{
+		Token:      token,
+		Path:       r.URL.RequestURI(),
+		RemoteAddr: r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+		Time:       time.Now(),
+	})
+}
+
+// handleCallback serves /ssrf/{token}.
+func handleCallback(w http.ResponseWriter, r *http.Request) {
+	token := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/ssrf/"), "/", 2)[0]
+	recordHit(token, r)
+	w.Write([]byte("ok"))
+}
+
+// handleMetadata serves /meta/{token}/... and imitates a cloud metadata
+// service whose credentials carry a canary, so full-read SSRF can be told
+// apart from blind fetches.
+func handleMetadata(w http.ResponseWriter, r *http.Request) {
+	token := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/meta/"), "/", 2)[0]
+	recordHit(token, r)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{
+  "Code" : "Success",
+  "Type" : "AWS-HMAC",
+  "AccessKeyId" : "ASIAEXAMPLECANARY",
+  "SecretAccessKey" : "%s",
+  "Token" : "%s"
+}`, canary(token), canary(token))
+}
+
+func canary(token string) string {
+	return "ssrf-canary-" + token
+}
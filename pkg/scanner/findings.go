@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"io"
+	"log"
+	"net/http"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// SaveFindings persists findings of an active scan of stored request
+// #requestID, filling in the affected host and path from req.
+func SaveFindings(requestID int, req *http.Request, findings []storage.Finding) []storage.Finding {
+	for i := range findings {
+		f := &findings[i]
+		f.Source = "active"
+		if f.Host == "" {
+			f.Host = req.URL.Host
+		}
+		if f.Path == "" {
+			f.Path = req.URL.Path
+		}
+		if f.RequestID == 0 {
+			f.RequestID = requestID
+		}
+		if _, err := storage.SaveFinding(f); err != nil {
+			log.Printf("Error saving finding for #%d: %v", requestID, err)
+		}
+	}
+	return findings
+}
+
+// recordEvidence stores an attack request and its response so that a
+// finding can point at the exchange that proved it.
+func recordEvidence(req *http.Request, resp *http.Response, respBody []byte) (requestID, responseID int) {
+	var body []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	requestID, err := storage.SaveRequest(req, body)
+	if err != nil {
+		log.Printf("Error saving scan evidence request: %v", err)
+		return 0, 0
+	}
+	if resp == nil {
+		return requestID, 0
+	}
+	responseID, err = storage.SaveResponse(requestID, resp, respBody)
+	if err != nil {
+		log.Printf("Error saving scan evidence response for #%d: %v", requestID, err)
+	}
+	return requestID, responseID
+}
@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// JobKinds lists the scans that can be queued; "all" runs every one of them.
+var JobKinds = []string{"basic", "xxe", "ssrf", "all"}
+
+var (
+	jobQueue chan int
+
+	runningMu sync.Mutex
+	running   = map[int]context.CancelFunc{}
+)
+
+type progressKey struct{}
+
+// withProgress attaches a progress callback to ctx for long-running scanners.
+func withProgress(ctx context.Context, fn func(done, total int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func reportProgress(ctx context.Context, done, total int) {
+	if fn, ok := ctx.Value(progressKey{}).(func(done, total int)); ok && total > 0 {
+		fn(done, total)
+	}
+}
+
+func ValidJobKind(kind string) bool {
+	for _, k := range JobKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// StartJobRunner starts the scan worker pool and re-queues jobs that were
+// queued or running when the process last stopped. Interrupted jobs start
+// over; findings deduplication keeps the results stable.
+func StartJobRunner(workers int) error {
+	jobQueue = make(chan int, 1024)
+	for i := 0; i < workers; i++ {
+		go jobWorker()
+	}
+
+	if err := storage.RequeueScanJobs(); err != nil {
+		return err
+	}
+	jobs, err := storage.ListUnfinishedScanJobs()
+	if err != nil {
+		return err
+	}
+	go func() {
+		for _, j := range jobs {
+			jobQueue <- j.ID
+		}
+	}()
+	if len(jobs) > 0 {
+		log.Printf("Resuming %d scan jobs", len(jobs))
+	}
+	return nil
+}
+
+// SubmitJob creates a scan job for stored request #requestID and queues it.
+func SubmitJob(requestID int, kind string) (*storage.ScanJob, error) {
+	if !ValidJobKind(kind) {
+		return nil, fmt.Errorf("unknown scan kind %q", kind)
+	}
+	if jobQueue == nil {
+		return nil, errors.New("scan job runner is not started")
+	}
+	job, err := storage.CreateScanJob(requestID, kind)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case jobQueue <- job.ID:
+	default:
+		storage.FinishScanJob(job.ID, storage.JobFailed, 0, "job queue is full")
+		return nil, errors.New("scan job queue is full")
+	}
+	return job, nil
+}
+
+// CancelJob cancels a queued job or interrupts a running one.
+func CancelJob(id int) (bool, error) {
+	ok, err := storage.CancelScanJob(id)
+	if err != nil || !ok {
+		return ok, err
+	}
+	runningMu.Lock()
+	if cancel, found := running[id]; found {
+		cancel()
+	}
+	runningMu.Unlock()
+	return true, nil
+}
+
+func jobWorker() {
+	for id := range jobQueue {
+		// Registered before the job is marked running so that CancelJob
+		// always finds a running job's cancel func
+		ctx, cancel := context.WithCancel(context.Background())
+		runningMu.Lock()
+		running[id] = cancel
+		runningMu.Unlock()
+		done := func() {
+			runningMu.Lock()
+			delete(running, id)
+			runningMu.Unlock()
+			cancel()
+		}
+
+		started, err := storage.StartScanJob(id)
+		if err != nil {
+			log.Printf("Error starting scan job %d: %v", id, err)
+			done()
+			continue
+		}
+		if !started {
+			done()
+			continue
+		}
+
+		count, err := runJob(ctx, id)
+		done()
+
+		status, msg := storage.JobDone, ""
+		if err != nil {
+			status, msg = storage.JobFailed, err.Error()
+		}
+		if err := storage.FinishScanJob(id, status, count, msg); err != nil {
+			log.Printf("Error finishing scan job %d: %v", id, err)
+		}
+	}
+}
+
+// runJob executes the scanners of job #id and returns the number of
+// findings it saved.
+func runJob(ctx context.Context, id int) (int, error) {
+	job, err := storage.GetScanJob(id)
+	if err != nil {
+		return 0, err
+	}
+	req, err := storage.GetRequestByID(job.RequestID)
+	if err != nil {
+		return 0, err
+	}
+
+	stages := []string{job.Kind}
+	if job.Kind == "all" {
+		stages = []string{"basic", "xxe", "ssrf"}
+	}
+
+	lastPercent := -1
+	count := 0
+	for i, stage := range stages {
+		stageCtx := withProgress(ctx, func(done, total int) {
+			percent := (i*100 + done*100/total) / len(stages)
+			if percent != lastPercent {
+				lastPercent = percent
+				storage.UpdateScanJobProgress(id, percent)
+			}
+		})
+
+		findings, err := runStage(stageCtx, stage, req)
+		count += len(SaveFindings(job.RequestID, req, findings))
+		if err != nil {
+			return count, err
+		}
+		reportProgress(stageCtx, 1, 1)
+	}
+	return count, nil
+}
+
+func runStage(ctx context.Context, kind string, req *http.Request) ([]storage.Finding, error) {
+	switch kind {
+	case "basic":
+		return ScanBasic(req).Findings, nil
+	case "xxe":
+		result, err := ScanXXE(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return result.Findings, ctx.Err()
+	case "ssrf":
+		result, err := ScanSSRF(ctx, req)
+		if result == nil {
+			return nil, err
+		}
+		return result.Findings, err
+	}
+	return nil, fmt.Errorf("unknown scan kind %q", kind)
+}
@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// hostLimiter caps concurrent active-scan requests per host and spaces
+// them out to a maximum rate.
+type hostLimiter struct {
+	mu          sync.Mutex
+	hosts       map[string]*hostSlot
+	concurrency int
+	interval    time.Duration
+}
+
+type hostSlot struct {
+	sem  chan struct{}
+	mu   sync.Mutex
+	next time.Time
+}
+
+var limiter = &hostLimiter{
+	hosts:       map[string]*hostSlot{},
+	concurrency: 2,
+	interval:    100 * time.Millisecond,
+}
+
+// HostLimits caps the active-scan traffic sent to each host.
+type HostLimits struct {
+	Concurrency int     `json:"concurrency"` // requests in flight
+	PerSecond   float64 `json:"per_second"`  // requests started per second
+}
+
+// GetHostLimits returns the current limits.
+func GetHostLimits() HostLimits {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return HostLimits{Concurrency: limiter.concurrency, PerSecond: float64(time.Second) / float64(limiter.interval)}
+}
+
+// SetHostLimits configures how many scan requests may be in flight per
+// host and how many may be started per second. Values that are not
+// positive are left unchanged.
+func SetHostLimits(concurrency int, perSecond float64) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if concurrency > 0 {
+		limiter.concurrency = concurrency
+	}
+	if perSecond > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	limiter.hosts = map[string]*hostSlot{}
+}
+
+func (l *hostLimiter) slot(host string) (*hostSlot, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.hosts[host]
+	if !ok {
+		s = &hostSlot{sem: make(chan struct{}, l.concurrency)}
+		l.hosts[host] = s
+	}
+	return s, l.interval
+}
+
+// acquire blocks until a request to host may be sent. The returned
+// function releases the concurrency slot.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	s, interval := l.slot(host)
+
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-s.sem }
+
+	s.mu.Lock()
+	now := time.Now()
+	wait := s.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	s.next = now.Add(wait + interval)
+	s.mu.Unlock()
+
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
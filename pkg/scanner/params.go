@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// Param is a single injectable input of a stored request.
+type Param struct {
+	Name     string `json:"name"`
+	Location string `json:"location"` // query, post or json
+	Value    string `json:"value"`
+}
+
+const maxResponseBody = 1 << 20
+
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// extractParams lists query, form and top-level JSON string parameters of req.
+func extractParams(req *http.Request, body []byte) []Param {
+	var params []Param
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			params = append(params, Param{Name: name, Location: "query", Value: v})
+		}
+	}
+
+	ct := req.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(ct, "application/x-www-form-urlencoded"):
+		form, _ := url.ParseQuery(string(body))
+		for name, values := range form {
+			for _, v := range values {
+				params = append(params, Param{Name: name, Location: "post", Value: v})
+			}
+		}
+	case strings.Contains(ct, "json"):
+		var obj map[string]interface{}
+		if json.Unmarshal(body, &obj) == nil {
+			for name, v := range obj {
+				if s, ok := v.(string); ok {
+					params = append(params, Param{Name: name, Location: "json", Value: s})
+				}
+			}
+		}
+	}
+	return params
+}
+
+// withParam clones req with parameter p set to value.
+func withParam(ctx context.Context, req *http.Request, body []byte, p Param, value string) (*http.Request, error) {
+	u := *req.URL
+	newBody := body
+
+	switch p.Location {
+	case "query":
+		q := u.Query()
+		q.Set(p.Name, value)
+		u.RawQuery = q.Encode()
+	case "post":
+		form, _ := url.ParseQuery(string(body))
+		form.Set(p.Name, value)
+		newBody = []byte(form.Encode())
+	case "json":
+		var obj map[string]interface{}
+		if err := json.Unmarshal(body, &obj); err != nil {
+			return nil, fmt.Errorf("withParam: %w", err)
+		}
+		obj[p.Name] = value
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("withParam: %w", err)
+		}
+		newBody = b
+	default:
+		return nil, fmt.Errorf("withParam: unknown location %q", p.Location)
+	}
+
+	return cloneRequest(ctx, req, u.String(), newBody)
+}
+
+func cloneRequest(ctx context.Context, req *http.Request, target string, body []byte) (*http.Request, error) {
+	newReq, err := http.NewRequestWithContext(ctx, req.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.Header {
+		newReq.Header[k] = v
+	}
+	newReq.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return newReq, nil
+}
+
+// send performs req and returns the response together with its decoded,
+// size-capped body.
+func send(req *http.Request) (*http.Response, []byte, error) {
+	release, err := limiter.acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return resp, body, err
+	}
+	return resp, storage.DecodeBody(resp.Header, body), nil
+}
@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+type passiveItem struct {
+	requestID  int
+	responseID int
+	req        *http.Request
+	resp       *http.Response
+	body       []byte
+}
+
+var passiveQueue chan passiveItem
+
+// StartPassiveScanner starts workers that analyse captured traffic.
+// Until it is called Passive silently drops everything.
+func StartPassiveScanner(workers int) {
+	passiveQueue = make(chan passiveItem, 256)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for item := range passiveQueue {
+				for _, f := range passiveChecks(item) {
+					if _, err := storage.SaveFinding(&f); err != nil {
+						log.Printf("Error saving passive finding for #%d: %v", item.requestID, err)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// Passive queues a captured exchange for analysis without blocking the
+// proxy; respBody must be the raw body as received from upstream.
+func Passive(requestID, responseID int, req *http.Request, resp *http.Response, respBody []byte) {
+	if passiveQueue == nil {
+		return
+	}
+	item := passiveItem{
+		requestID:  requestID,
+		responseID: responseID,
+		req:        req,
+		resp:       resp,
+		body:       storage.DecodeBody(resp.Header, respBody),
+	}
+	select {
+	case passiveQueue <- item:
+	default:
+		log.Printf("Passive scanner queue full, skipping #%d", requestID)
+	}
+}
+
+var stackTracePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`Traceback \(most recent call last\)`),
+	regexp.MustCompile(`goroutine \d+ \[running\]`),
+	regexp.MustCompile(`at [\w$.]+\([\w$]+\.java:\d+\)`),
+	regexp.MustCompile(`at \S+ in \S+:line \d+`),
+	regexp.MustCompile(`(?m)^\s*at .+\(.+\.js:\d+:\d+\)`),
+	regexp.MustCompile(`(?i)(fatal|parse) error:.+ on line \d+`),
+	regexp.MustCompile(`System\.\w+Exception`),
+}
+
+var secretPatterns = map[string]*regexp.Regexp{
+	"AWS access key":  regexp.MustCompile(`AKIA[0-9A-Z]{16}`),
+	"Google API key":  regexp.MustCompile(`AIza[0-9A-Za-z\-_]{35}`),
+	"GitHub token":    regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36,}`),
+	"Slack token":     regexp.MustCompile(`xox[abprs]-[A-Za-z0-9-]{10,}`),
+	"Stripe key":      regexp.MustCompile(`sk_live_[0-9a-zA-Z]{24,}`),
+	"private key":     regexp.MustCompile(`-----BEGIN (RSA |EC |DSA |OPENSSH )?PRIVATE KEY-----`),
+	"generic API key": regexp.MustCompile(`(?i)(api[_-]?key|secret|access[_-]?token)["']?\s*[:=]\s*["'][A-Za-z0-9_\-]{16,}["']`),
+}
+
+var (
+	mixedContentPattern = regexp.MustCompile(`(?i)<(script|img|iframe|link|audio|video|source|embed|form)[^>]+(src|href|action)\s*=\s*["']http://[^"']+`)
+	versionPattern      = regexp.MustCompile(`\d+\.\d+`)
+)
+
+func passiveChecks(item passiveItem) []storage.Finding {
+	var findings []storage.Finding
+	add := func(typ, severity, confidence, param, detail, evidence string) {
+		findings = append(findings, storage.Finding{
+			Source:     "passive",
+			Type:       typ,
+			Severity:   severity,
+			Confidence: confidence,
+			Host:       item.req.URL.Host,
+			Path:       item.req.URL.Path,
+			Parameter:  param,
+			Detail:     detail,
+			Evidence:   evidence,
+			RequestID:  item.requestID,
+			ResponseID: item.responseID,
+		})
+	}
+	// addHost reports an issue of the whole host, once per host rather
+	// than once per path
+	addHost := func(typ, severity, confidence, param, detail, evidence string) {
+		add(typ, severity, confidence, param, detail, evidence)
+		findings[len(findings)-1].Path = ""
+	}
+
+	h := item.resp.Header
+	isHTTPS := item.req.URL.Scheme == "https"
+	ct := strings.ToLower(h.Get("Content-Type"))
+	isHTML := strings.Contains(ct, "text/html")
+
+	// Security headers
+	if isHTML && h.Get("Content-Security-Policy") == "" {
+		add("missing-csp", "low", "certain", "Content-Security-Policy", "Response has no Content-Security-Policy header", "")
+	}
+	if isHTTPS && h.Get("Strict-Transport-Security") == "" {
+		addHost("missing-hsts", "low", "certain", "Strict-Transport-Security", "HTTPS response has no Strict-Transport-Security header", "")
+	}
+	if isHTML && h.Get("X-Frame-Options") == "" &&
+		!strings.Contains(h.Get("Content-Security-Policy"), "frame-ancestors") {
+		add("missing-x-frame-options", "low", "certain", "X-Frame-Options", "Response can be framed: no X-Frame-Options or frame-ancestors", "")
+	}
+
+	// Cookie flags
+	for _, c := range item.resp.Cookies() {
+		var missing []string
+		if isHTTPS && !c.Secure {
+			missing = append(missing, "Secure")
+		}
+		if !c.HttpOnly {
+			missing = append(missing, "HttpOnly")
+		}
+		if c.SameSite == http.SameSiteDefaultMode {
+			missing = append(missing, "SameSite")
+		}
+		if len(missing) > 0 {
+			add("insecure-cookie", "low", "certain", c.Name,
+				fmt.Sprintf("Cookie %q is missing %s", c.Name, strings.Join(missing, ", ")),
+				c.String())
+		}
+	}
+
+	// Server banners
+	for _, name := range []string{"Server", "X-Powered-By", "X-AspNet-Version", "X-AspNetMvc-Version"} {
+		v := h.Get(name)
+		if v == "" || (name == "Server" && !versionPattern.MatchString(v)) {
+			continue
+		}
+		addHost("server-banner", "info", "certain", name, fmt.Sprintf("%s header discloses software version", name), name+": "+v)
+	}
+
+	if !isText(ct) || len(item.body) == 0 {
+		return findings
+	}
+
+	for _, re := range stackTracePatterns {
+		if loc := re.FindIndex(item.body); loc != nil {
+			add("stack-trace", "medium", "firm", "", "Response contains a stack trace", excerpt(item.body, loc))
+			break
+		}
+	}
+
+	for name, re := range secretPatterns {
+		confidence := "firm"
+		if name == "generic API key" {
+			confidence = "tentative"
+		}
+		if loc := re.FindIndex(item.body); loc != nil {
+			add("secret-leak", "high", confidence, name, fmt.Sprintf("Response contains what looks like a %s", name), excerpt(item.body, loc))
+		}
+	}
+
+	if isHTTPS && isHTML {
+		if loc := mixedContentPattern.FindIndex(item.body); loc != nil {
+			add("mixed-content", "medium", "firm", "", "HTTPS page loads resources over plain HTTP", excerpt(item.body, loc))
+		}
+	}
+
+	return findings
+}
+
+func isText(ct string) bool {
+	for _, t := range []string{"text/", "json", "javascript", "xml"} {
+		if strings.Contains(ct, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// excerpt returns the matched region of body with a little context.
+func excerpt(body []byte, loc []int) string {
+	start, end := loc[0]-40, loc[1]+40
+	if start < 0 {
+		start = 0
+	}
+	if end > len(body) {
+		end = len(body)
+	}
+	return string(body[start:end])
+}
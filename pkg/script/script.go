@@ -0,0 +1,363 @@
+// Package script runs user-supplied Starlark hooks on proxied traffic.
+//
+// A script may define
+//
+//	def onRequest(req): ...
+//	def onResponse(req, resp): ...
+//
+// where req is a dict with "method", "url", "headers" and "body" and resp
+// a dict with "status", "headers" and "body". Hooks modify the dicts in
+// place. Header values are strings, or lists of strings for repeated
+// headers. A failing or slow script is logged and its changes discarded;
+// the traffic continues as if the script did not exist.
+package script
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"MITM_PROXY/pkg/storage"
+)
+
+const (
+	defaultTimeout = time.Second
+	maxSteps       = 50_000_000
+)
+
+type loaded struct {
+	storage.Script
+	onRequest  starlark.Callable
+	onResponse starlark.Callable
+}
+
+var (
+	mu     sync.RWMutex
+	active []*loaded
+
+	errMu      sync.Mutex
+	lastErrors = map[int]string{}
+)
+
+var fileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+func timeout(s storage.Script) time.Duration {
+	if s.TimeoutMS > 0 {
+		return time.Duration(s.TimeoutMS) * time.Millisecond
+	}
+	return defaultTimeout
+}
+
+func newThread(s storage.Script) (*starlark.Thread, func()) {
+	thread := &starlark.Thread{
+		Name: fmt.Sprintf("%d:%s", s.ID, s.Name),
+		Print: func(t *starlark.Thread, msg string) {
+			log.Printf("[script %s] %s", t.Name, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+	timer := time.AfterFunc(timeout(s), func() { thread.Cancel("timeout") })
+	return thread, func() { timer.Stop() }
+}
+
+func compile(s storage.Script) (l *loaded, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	thread, stop := newThread(s)
+	defer stop()
+	globals, err := starlark.ExecFileOptions(fileOptions, thread, fmt.Sprintf("script-%d.star", s.ID), s.Source, predeclared)
+	if err != nil {
+		return nil, err
+	}
+
+	l = &loaded{Script: s}
+	for name, dst := range map[string]*starlark.Callable{"onRequest": &l.onRequest, "onResponse": &l.onResponse} {
+		v, ok := globals[name]
+		if !ok {
+			continue
+		}
+		fn, ok := v.(starlark.Callable)
+		if !ok {
+			return nil, fmt.Errorf("%s is not callable", name)
+		}
+		*dst = fn
+	}
+	if l.onRequest == nil && l.onResponse == nil {
+		return nil, fmt.Errorf("script defines neither onRequest nor onResponse")
+	}
+	return l, nil
+}
+
+// Validate compiles s without activating it.
+func Validate(s storage.Script) error {
+	_, err := compile(s)
+	return err
+}
+
+// Load replaces the active scripts with the enabled scripts from storage.
+func Load() error {
+	stored, err := storage.ListScripts()
+	if err != nil {
+		return err
+	}
+	var next []*loaded
+	errMu.Lock()
+	lastErrors = map[int]string{}
+	errMu.Unlock()
+	for _, s := range stored {
+		if !s.Enabled {
+			continue
+		}
+		l, err := compile(s)
+		if err != nil {
+			recordError(s, err)
+			continue
+		}
+		next = append(next, l)
+	}
+
+	mu.Lock()
+	active = next
+	mu.Unlock()
+	return nil
+}
+
+// Errors returns the last compile or runtime error of each script.
+func Errors() map[int]string {
+	errMu.Lock()
+	defer errMu.Unlock()
+	out := make(map[int]string, len(lastErrors))
+	for id, e := range lastErrors {
+		out[id] = e
+	}
+	return out
+}
+
+func recordError(s storage.Script, err error) {
+	log.Printf("Script %d (%s) failed: %v", s.ID, s.Name, err)
+	errMu.Lock()
+	lastErrors[s.ID] = err.Error()
+	errMu.Unlock()
+}
+
+func scripts() []*loaded {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active
+}
+
+func call(s *loaded, fn starlark.Callable, args starlark.Tuple) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	thread, stop := newThread(s.Script)
+	defer stop()
+	_, err = starlark.Call(thread, fn, args, nil)
+	return err
+}
+
+// OnRequest runs the onRequest hooks over req and returns the new body.
+func OnRequest(req *http.Request, body []byte) []byte {
+	for _, s := range scripts() {
+		if s.onRequest == nil {
+			continue
+		}
+		d := requestDict(req, body)
+		if err := call(s, s.onRequest, starlark.Tuple{d}); err != nil {
+			recordError(s.Script, err)
+			continue
+		}
+		newBody, err := applyRequest(d, req)
+		if err != nil {
+			recordError(s.Script, err)
+			continue
+		}
+		body = newBody
+	}
+	return body
+}
+
+// OnResponse runs the onResponse hooks over resp and returns the new body.
+func OnResponse(req *http.Request, resp *http.Response, body []byte) []byte {
+	decoded := false
+	for _, s := range scripts() {
+		if s.onResponse == nil {
+			continue
+		}
+		if !decoded {
+			body = storage.DecodeResponseBody(resp, body)
+			decoded = true
+		}
+		d := responseDict(resp, body)
+		if err := call(s, s.onResponse, starlark.Tuple{requestDict(req, nil), d}); err != nil {
+			recordError(s.Script, err)
+			continue
+		}
+		newBody, err := applyResponse(d, resp)
+		if err != nil {
+			recordError(s.Script, err)
+			continue
+		}
+		body = newBody
+	}
+	return body
+}
+
+func headerDict(h http.Header) *starlark.Dict {
+	d := starlark.NewDict(len(h))
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := h[name]
+		if len(values) == 1 {
+			d.SetKey(starlark.String(name), starlark.String(values[0]))
+			continue
+		}
+		list := make([]starlark.Value, len(values))
+		for i, v := range values {
+			list[i] = starlark.String(v)
+		}
+		d.SetKey(starlark.String(name), starlark.NewList(list))
+	}
+	return d
+}
+
+func requestDict(req *http.Request, body []byte) *starlark.Dict {
+	d := starlark.NewDict(4)
+	d.SetKey(starlark.String("method"), starlark.String(req.Method))
+	d.SetKey(starlark.String("url"), starlark.String(req.URL.String()))
+	d.SetKey(starlark.String("headers"), headerDict(req.Header))
+	d.SetKey(starlark.String("body"), starlark.String(body))
+	return d
+}
+
+func responseDict(resp *http.Response, body []byte) *starlark.Dict {
+	d := starlark.NewDict(3)
+	d.SetKey(starlark.String("status"), starlark.MakeInt(resp.StatusCode))
+	d.SetKey(starlark.String("headers"), headerDict(resp.Header))
+	d.SetKey(starlark.String("body"), starlark.String(body))
+	return d
+}
+
+func getString(d *starlark.Dict, key string) (string, error) {
+	v, found, err := d.Get(starlark.String(key))
+	if err != nil || !found {
+		return "", fmt.Errorf("%q is missing", key)
+	}
+	s, ok := starlark.AsString(v)
+	if !ok {
+		return "", fmt.Errorf("%q must be a string, got %s", key, v.Type())
+	}
+	return s, nil
+}
+
+func getHeader(d *starlark.Dict) (http.Header, error) {
+	v, found, err := d.Get(starlark.String("headers"))
+	if err != nil || !found {
+		return nil, fmt.Errorf(`"headers" is missing`)
+	}
+	hd, ok := v.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf(`"headers" must be a dict, got %s`, v.Type())
+	}
+	h := http.Header{}
+	for _, item := range hd.Items() {
+		name, ok := starlark.AsString(item[0])
+		if !ok {
+			return nil, fmt.Errorf("header names must be strings")
+		}
+		switch val := item[1].(type) {
+		case starlark.String:
+			h.Add(name, string(val))
+		case *starlark.List:
+			for i := 0; i < val.Len(); i++ {
+				s, ok := starlark.AsString(val.Index(i))
+				if !ok {
+					return nil, fmt.Errorf("header %q values must be strings", name)
+				}
+				h.Add(name, s)
+			}
+		default:
+			return nil, fmt.Errorf("header %q must be a string or list", name)
+		}
+	}
+	return h, nil
+}
+
+func applyRequest(d *starlark.Dict, req *http.Request) ([]byte, error) {
+	method, err := getString(d, "method")
+	if err != nil {
+		return nil, err
+	}
+	rawURL, err := getString(d, "url")
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("bad url %q", rawURL)
+	}
+	header, err := getHeader(d)
+	if err != nil {
+		return nil, err
+	}
+	body, err := getString(d, "body")
+	if err != nil {
+		return nil, err
+	}
+
+	req.Method = method
+	if u.String() != req.URL.String() {
+		req.URL = u
+		req.Host = u.Host
+	}
+	req.Header = header
+	return []byte(body), nil
+}
+
+func applyResponse(d *starlark.Dict, resp *http.Response) ([]byte, error) {
+	v, found, err := d.Get(starlark.String("status"))
+	if err != nil || !found {
+		return nil, fmt.Errorf(`"status" is missing`)
+	}
+	var status int
+	if err := starlark.AsInt(v, &status); err != nil || status < 100 || status > 999 {
+		return nil, fmt.Errorf(`"status" must be an HTTP status code`)
+	}
+	header, err := getHeader(d)
+	if err != nil {
+		return nil, err
+	}
+	body, err := getString(d, "body")
+	if err != nil {
+		return nil, err
+	}
+
+	if status != resp.StatusCode {
+		resp.StatusCode = status
+		resp.Status = fmt.Sprintf("%d %s", status, http.StatusText(status))
+	}
+	resp.Header = header
+	return []byte(body), nil
+}
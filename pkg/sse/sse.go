@@ -0,0 +1,141 @@
+// Package sse parses Server-Sent Events streams (text/event-stream) as
+// they arrive, following the WHATWG HTML event stream format.
+package sse
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Event is one dispatched event. Type is empty for the default "message"
+// type; Retry is -1 unless the event set the reconnection time.
+type Event struct {
+	ID    string
+	Type  string
+	Data  string
+	Retry int
+}
+
+// IsEventStream reports whether h is the header of an event stream that
+// can be parsed as is, i.e. without a Content-Encoding.
+func IsEventStream(h http.Header) bool {
+	mt, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mt == "text/event-stream" && h.Get("Content-Encoding") == ""
+}
+
+// Parser turns pieces of an event stream into events. The zero value is
+// ready to use.
+type Parser struct {
+	// MaxLine bounds a buffered line; longer ones are dropped. Zero means
+	// no limit.
+	MaxLine int
+
+	buf      []byte
+	skipping bool // dropping the rest of an overlong line
+	started  bool // past the optional byte order mark
+	cr       bool // last piece ended with CR, which may be half of CRLF
+
+	id       string
+	typ      string
+	data     []string
+	hasData  bool
+	retry    int
+	hasRetry bool
+}
+
+// Feed parses the next piece of the stream and returns the events it
+// completed. An event still incomplete when the stream ends is discarded,
+// as browsers do.
+func (p *Parser) Feed(b []byte) []Event {
+	var events []Event
+	if p.cr && len(b) > 0 && b[0] == '\n' {
+		b = b[1:]
+	}
+	p.cr = false
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "\r\n")
+		if i < 0 {
+			p.buffer(b)
+			break
+		}
+		p.buffer(b[:i])
+		if b[i] == '\r' {
+			if i+1 == len(b) {
+				p.cr = true
+			} else if b[i+1] == '\n' {
+				i++
+			}
+		}
+		b = b[i+1:]
+
+		line := p.buf
+		p.buf = p.buf[:0]
+		if p.skipping {
+			p.skipping = false
+			continue
+		}
+		if !p.started {
+			line = bytes.TrimPrefix(line, []byte("\xef\xbb\xbf"))
+			p.started = true
+		}
+		if ev, ok := p.line(string(line)); ok {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func (p *Parser) buffer(b []byte) {
+	if p.skipping {
+		return
+	}
+	if p.MaxLine > 0 && len(p.buf)+len(b) > p.MaxLine {
+		p.buf = p.buf[:0]
+		p.skipping = true
+		return
+	}
+	p.buf = append(p.buf, b...)
+}
+
+// line processes one line, reporting an event when it dispatches one.
+func (p *Parser) line(line string) (Event, bool) {
+	if line == "" {
+		return p.dispatch()
+	}
+	if strings.HasPrefix(line, ":") {
+		return Event{}, false // comment
+	}
+	field, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+	switch field {
+	case "event":
+		p.typ = value
+	case "data":
+		p.data = append(p.data, value)
+		p.hasData = true
+	case "id":
+		if !strings.ContainsRune(value, 0) {
+			p.id = value
+		}
+	case "retry":
+		if n, err := strconv.Atoi(value); err == nil && strings.Trim(value, "0123456789") == "" {
+			p.retry, p.hasRetry = n, true
+		}
+	}
+	return Event{}, false
+}
+
+// dispatch completes the current event. The last event ID carries over to
+// the next events, as in browsers.
+func (p *Parser) dispatch() (Event, bool) {
+	ev := Event{ID: p.id, Type: p.typ, Data: strings.Join(p.data, "\n"), Retry: -1}
+	if p.hasRetry {
+		ev.Retry = p.retry
+	}
+	ok := p.hasData
+	p.typ, p.data, p.hasData, p.hasRetry = "", nil, false, false
+	return ev, ok
+}
@@ -0,0 +1,71 @@
+package sse
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []Event
+	}{
+		{"one", "data: hello\n\n", []Event{{Data: "hello", Retry: -1}}},
+		{"fields", "id: 1\nevent: update\nretry: 3000\ndata: x\n\n", []Event{{ID: "1", Type: "update", Data: "x", Retry: 3000}}},
+		{"multi-line data", "data: a\ndata:b\ndata\n\n", []Event{{Data: "a\nb\n", Retry: -1}}},
+		{"CRLF", "data: a\r\n\r\ndata: b\r\rdata: c\n\n", []Event{{Data: "a", Retry: -1}, {Data: "b", Retry: -1}, {Data: "c", Retry: -1}}},
+		{"comment", ": ping\n\ndata: x\n\n", []Event{{Data: "x", Retry: -1}}},
+		{"no data", "event: e\nid: 7\n\ndata: x\n\n", []Event{{ID: "7", Data: "x", Retry: -1}}},
+		{"ID carries over", "id: 1\ndata: a\n\ndata: b\n\nid\ndata: c\n\n", []Event{{ID: "1", Data: "a", Retry: -1}, {ID: "1", Data: "b", Retry: -1}, {Data: "c", Retry: -1}}},
+		{"ID with NUL ignored", "id: 1\nid: a\x00b\ndata: x\n\n", []Event{{ID: "1", Data: "x", Retry: -1}}},
+		{"bad retry", "retry: 1s\nretry: -5\ndata: x\n\n", []Event{{Data: "x", Retry: -1}}},
+		{"byte order mark", "\xef\xbb\xbfdata: x\n\n", []Event{{Data: "x", Retry: -1}}},
+		{"incomplete", "data: x\n\ndata: y\n", []Event{{Data: "x", Retry: -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Parser
+			if got := p.Feed([]byte(tt.stream)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("whole stream: events = %+v, want %+v", got, tt.want)
+			}
+
+			// The same stream a byte at a time, CRLF split included
+			p = Parser{}
+			var got []Event
+			for i := range len(tt.stream) {
+				got = append(got, p.Feed([]byte(tt.stream[i:i+1]))...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("byte by byte: events = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParserMaxLine(t *testing.T) {
+	p := Parser{MaxLine: 10}
+	got := p.Feed([]byte("data: 0123456789\ndata: ok\n\n"))
+	if want := []Event{{Data: "ok", Retry: -1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %+v, want %+v", got, want)
+	}
+}
+
+func TestIsEventStream(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		want   bool
+	}{
+		{http.Header{"Content-Type": {"text/event-stream"}}, true},
+		{http.Header{"Content-Type": {"text/event-stream; charset=utf-8"}}, true},
+		{http.Header{"Content-Type": {"text/event-stream"}, "Content-Encoding": {"gzip"}}, false},
+		{http.Header{"Content-Type": {"text/plain"}}, false},
+		{http.Header{}, false},
+	}
+	for _, tt := range tests {
+		if got := IsEventStream(tt.header); got != tt.want {
+			t.Errorf("IsEventStream(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// BlockRule blocks or allows traffic by host glob, URL regexp or IP CIDR.
+type BlockRule struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Kind    string `json:"kind"` // host, url or cidr
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"` // block, reset or allow
+	Status  int    `json:"status"` // returned by block
+}
+
+const blockRuleColumns = `id, name, enabled, kind, pattern, action, status`
+
+func scanBlockRule(row rowScanner) (BlockRule, error) {
+	var r BlockRule
+	err := row.Scan(&r.ID, &r.Name, &r.Enabled, &r.Kind, &r.Pattern, &r.Action, &r.Status)
+	return r, err
+}
+
+func ListBlockRules() ([]BlockRule, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT `+blockRuleColumns+` FROM block_rules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListBlockRules query: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []BlockRule{}
+	for rows.Next() {
+		r, err := scanBlockRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListBlockRules scan: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func GetBlockRule(id int) (*BlockRule, error) {
+	ctx := context.Background()
+
+	r, err := scanBlockRule(pool.QueryRow(ctx, `SELECT `+blockRuleColumns+` FROM block_rules WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("GetBlockRule scan: %w", err)
+	}
+	return &r, nil
+}
+
+func CreateBlockRule(r *BlockRule) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO block_rules (name, enabled, kind, pattern, action, status)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    RETURNING id
+    `, r.Name, r.Enabled, r.Kind, r.Pattern, r.Action, r.Status)
+	if err := row.Scan(&r.ID); err != nil {
+		return fmt.Errorf("CreateBlockRule scan: %w", err)
+	}
+	return nil
+}
+
+func UpdateBlockRule(r *BlockRule) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE block_rules SET name = $1, enabled = $2, kind = $3, pattern = $4, action = $5, status = $6
+    WHERE id = $7
+    `, r.Name, r.Enabled, r.Kind, r.Pattern, r.Action, r.Status, r.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateBlockRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateBlockRule: rule %d not found", r.ID)
+	}
+	return nil
+}
+
+func DeleteBlockRule(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM block_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteBlockRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteBlockRule: rule %d not found", id)
+	}
+	return nil
+}
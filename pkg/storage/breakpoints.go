@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Breakpoint holds responses matching all of its conditions for manual
+// review, independent of the intercept configuration. Empty conditions
+// match everything.
+type Breakpoint struct {
+	ID        int    `json:"id"`
+	Enabled   bool   `json:"enabled"`
+	Host      string `json:"host"`   // glob
+	Path      string `json:"path"`   // regexp
+	Method    string `json:"method"` // exact
+	MinStatus int    `json:"min_status"`
+	MaxStatus int    `json:"max_status"`
+	JSONField string `json:"json_field"` // dotted path into a JSON body, e.g. "error.code" or "items.0.id"
+	JSONValue string `json:"json_value"` // required value of JSONField, empty means present
+	Body      string `json:"body"`       // regexp on the decoded body
+}
+
+const breakpointColumns = `id, enabled, host, path, method, min_status, max_status, json_field, json_value, body`
+
+func scanBreakpoint(row rowScanner) (Breakpoint, error) {
+	var b Breakpoint
+	err := row.Scan(&b.ID, &b.Enabled, &b.Host, &b.Path, &b.Method, &b.MinStatus, &b.MaxStatus,
+		&b.JSONField, &b.JSONValue, &b.Body)
+	return b, err
+}
+
+func ListBreakpoints() ([]Breakpoint, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT `+breakpointColumns+` FROM breakpoints ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListBreakpoints query: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Breakpoint{}
+	for rows.Next() {
+		b, err := scanBreakpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListBreakpoints scan: %w", err)
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+func GetBreakpoint(id int) (*Breakpoint, error) {
+	ctx := context.Background()
+
+	b, err := scanBreakpoint(pool.QueryRow(ctx, `SELECT `+breakpointColumns+` FROM breakpoints WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("GetBreakpoint scan: %w", err)
+	}
+	return &b, nil
+}
+
+func CreateBreakpoint(b *Breakpoint) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO breakpoints (enabled, host, path, method, min_status, max_status, json_field, json_value, body)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    RETURNING id
+    `, b.Enabled, b.Host, b.Path, b.Method, b.MinStatus, b.MaxStatus, b.JSONField, b.JSONValue, b.Body)
+	if err := row.Scan(&b.ID); err != nil {
+		return fmt.Errorf("CreateBreakpoint scan: %w", err)
+	}
+	return nil
+}
+
+func UpdateBreakpoint(b *Breakpoint) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE breakpoints SET enabled = $1, host = $2, path = $3, method = $4, min_status = $5,
+      max_status = $6, json_field = $7, json_value = $8, body = $9
+    WHERE id = $10
+    `, b.Enabled, b.Host, b.Path, b.Method, b.MinStatus, b.MaxStatus, b.JSONField, b.JSONValue, b.Body, b.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateBreakpoint exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateBreakpoint: breakpoint %d not found", b.ID)
+	}
+	return nil
+}
+
+func DeleteBreakpoint(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM breakpoints WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteBreakpoint exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteBreakpoint: breakpoint %d not found", id)
+	}
+	return nil
+}
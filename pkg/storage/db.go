@@ -9,7 +9,11 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"MITM_PROXY/db"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -17,6 +21,8 @@ import (
 type RequestInfo struct {
 	ID         int             `json:"id"`
 	Method     string          `json:"method"`
+	Scheme     string          `json:"scheme"`
+	Host       string          `json:"host"`
 	Path       string          `json:"path"`
 	Query      json.RawMessage `json:"query_params"`
 	Headers    json.RawMessage `json:"headers"`
@@ -24,10 +30,23 @@ type RequestInfo struct {
 	PostParams json.RawMessage `json:"post_params"`
 	Body       string          `json:"body"`
 	CreatedAt  string          `json:"created_at"`
+
+	// TLSConnectionID is the intercepted TLS connection the request was
+	// sent over, if any, and JA3Hash and JA4 fingerprint its client.
+	TLSConnectionID *int   `json:"tls_connection_id"`
+	JA3Hash         string `json:"ja3_hash,omitempty"`
+	JA4             string `json:"ja4,omitempty"`
+
+	// Protocol is the client's HTTP version; HTTP/2 requests also keep
+	// their pseudo-headers.
+	Protocol      string          `json:"protocol"`
+	PseudoHeaders json.RawMessage `json:"pseudo_headers"`
+	Trailers      json.RawMessage `json:"trailers"`
 }
 
 var pool *pgxpool.Pool
 
+// Init connects to the database and brings its schema up to date.
 func Init(dsn string) error {
 	ctx := context.Background()
 	var err error
@@ -39,6 +58,10 @@ func Init(dsn string) error {
 		pool.Close()
 		return fmt.Errorf("db: cannot ping database: %w", err)
 	}
+	if _, err = pool.Exec(ctx, db.Schema); err != nil {
+		pool.Close()
+		return fmt.Errorf("db: cannot apply schema: %w", err)
+	}
 	return nil
 }
 
@@ -80,25 +103,51 @@ func SaveRequest(req *http.Request, rawBody []byte) (int, error) {
 
 	bodyToStore := ""
 	if len(postParams) == 0 && len(rawBody) > 0 {
-		bodyToStore = string(rawBody)
+		bodyToStore = textBody(rawBody)
+	}
+
+	scheme := req.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
 	}
+	host := req.URL.Host
+	if host == "" {
+		host = req.Host
+	}
+
+	pseudo := map[string]string{}
+	if req.ProtoMajor == 2 {
+		// Go folds the pseudo-headers into Method, URL and Host
+		pseudo = map[string]string{
+			":method":    req.Method,
+			":scheme":    scheme,
+			":authority": req.Host,
+			":path":      req.URL.RequestURI(),
+		}
+	}
+	pseudoJSON, _ := json.Marshal(pseudo)
 
 	const sqlInsert = `
     INSERT INTO requests
-      (method, path, query_params, headers, cookies, post_params, body)
+      (method, scheme, host, path, query_params, headers, cookies, post_params, body, protocol, pseudo_headers, trailers)
     VALUES
-      ($1, $2, $3::jsonb, $4::jsonb, $5::jsonb, $6::jsonb, $7)
+      ($1, $2, $3, $4, $5::jsonb, $6::jsonb, $7::jsonb, $8::jsonb, $9, $10, $11::jsonb, $12::jsonb)
     RETURNING id
     `
 	var id int
 	row := pool.QueryRow(ctx, sqlInsert,
 		req.Method,
+		scheme,
+		host,
 		req.URL.Path,
 		string(qpJSON),
 		string(hdrJSON),
 		string(cookiesJSON),
 		string(postJSON),
 		bodyToStore,
+		req.Proto,
+		string(pseudoJSON),
+		headerJSON(req.Trailer),
 	)
 	if err := row.Scan(&id); err != nil {
 		return 0, fmt.Errorf("SaveRequest scan: %w", err)
@@ -106,51 +155,148 @@ func SaveRequest(req *http.Request, rawBody []byte) (int, error) {
 	return id, nil
 }
 
-func SaveResponse(requestID int, resp *http.Response, rawBody []byte) error {
-	ctx := context.Background()
+func SaveResponse(requestID int, resp *http.Response, rawBody []byte) (int, error) {
+	return saveResponse("SaveResponse", requestID, resp, rawBody, false)
+}
 
-	bodyBytes := rawBody
-	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
-		if gr, err := gzip.NewReader(bytes.NewReader(rawBody)); err == nil {
-			if decoded, err2 := io.ReadAll(gr); err2 == nil {
-				bodyBytes = decoded
-			}
-			gr.Close()
-		}
-	}
+// SaveStreamedResponse stores the status and headers of a response that
+// is streamed to the client; FinishStreamedResponse adds the body once
+// the stream ends.
+func SaveStreamedResponse(requestID int, resp *http.Response) (int, error) {
+	return saveResponse("SaveStreamedResponse", requestID, resp, nil, true)
+}
 
+func saveResponse(caller string, requestID int, resp *http.Response, rawBody []byte, streamed bool) (int, error) {
+	ctx := context.Background()
+
+	bodyBytes := DecodeBody(resp.Header, rawBody)
 	hdrJSON, _ := json.Marshal(resp.Header)
 
+	pseudo := map[string]string{}
+	if resp.ProtoMajor == 2 {
+		pseudo[":status"] = strconv.Itoa(resp.StatusCode)
+	}
+	pseudoJSON, _ := json.Marshal(pseudo)
+
 	const sqlInsert = `
     INSERT INTO responses
-      (request_id, status_code, status_message, headers, body)
+      (request_id, status_code, status_message, headers, body, protocol, pseudo_headers, trailers, streamed)
     VALUES
-      ($1, $2, $3, $4::jsonb, $5)
+      ($1, $2, $3, $4::jsonb, $5, $6, $7::jsonb, $8::jsonb, $9)
+    RETURNING id
     `
-	if _, err := pool.Exec(ctx, sqlInsert,
+	var id int
+	row := pool.QueryRow(ctx, sqlInsert,
 		requestID,
 		resp.StatusCode,
 		resp.Status,
 		string(hdrJSON),
-		string(bodyBytes),
-	); err != nil {
-		return fmt.Errorf("SaveResponse exec: %w", err)
+		textBody(bodyBytes),
+		resp.Proto,
+		string(pseudoJSON),
+		headerJSON(resp.Trailer),
+		streamed,
+	)
+	if err := row.Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s scan: %w", caller, err)
+	}
+	return id, nil
+}
+
+// FinishStreamedResponse stores the captured body of streamed response
+// #id, truncated when the stream outgrew the capture limit, and its
+// trailers.
+func FinishStreamedResponse(id int, resp *http.Response, rawBody []byte, truncated bool) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE responses SET body = $2, trailers = $3::jsonb, truncated = $4
+    WHERE id = $1
+    `, id, textBody(DecodeBody(resp.Header, rawBody)), headerJSON(resp.Trailer), truncated)
+	if err != nil {
+		return fmt.Errorf("FinishStreamedResponse exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("FinishStreamedResponse: response %d not found", id)
 	}
 	return nil
 }
 
+// textBody returns body for a TEXT column. Postgres text can't hold NUL
+// bytes or invalid UTF-8, so such binary bodies (gRPC ones, for instance)
+// are left out rather than failing the insert.
+func textBody(body []byte) string {
+	if !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0 {
+		return ""
+	}
+	return string(body)
+}
+
+// headerJSON encodes h as a JSON object, {} when nil.
+func headerJSON(h http.Header) string {
+	if h == nil {
+		return "{}"
+	}
+	b, _ := json.Marshal(h)
+	return string(b)
+}
+
+// DecodeResponseBody decodes body for editing and drops Content-Encoding
+// from resp if that succeeded.
+func DecodeResponseBody(resp *http.Response, body []byte) []byte {
+	if resp.Header.Get("Content-Encoding") == "" {
+		return body
+	}
+	decoded := DecodeBody(resp.Header, body)
+	if !bytes.Equal(decoded, body) {
+		resp.Header.Del("Content-Encoding")
+	}
+	return decoded
+}
+
+// DecodeBody undoes gzip Content-Encoding, returning rawBody unchanged
+// for other encodings or on decode errors.
+func DecodeBody(header http.Header, rawBody []byte) []byte {
+	if !strings.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		return rawBody
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(rawBody))
+	if err != nil {
+		return rawBody
+	}
+	defer gr.Close()
+	decoded, err := io.ReadAll(gr)
+	if err != nil {
+		return rawBody
+	}
+	return decoded
+}
+
 func GetAllRequests() ([]RequestInfo, error) {
+	return queryRequests("GetAllRequests", "")
+}
+
+// GetRequestsByFingerprint returns the requests sent by TLS clients with
+// the given JA3 hash, JA3 string or JA4 fingerprint.
+func GetRequestsByFingerprint(fp string) ([]RequestInfo, error) {
+	return queryRequests("GetRequestsByFingerprint", "WHERE t.ja3_hash = $1 OR t.ja3 = $1 OR t.ja4 = $1", fp)
+}
+
+func queryRequests(caller, where string, args ...any) ([]RequestInfo, error) {
 	ctx := context.Background()
 
-	const sqlQuery = `
-    SELECT id, method, path, query_params, headers, cookies, post_params, body, created_at
-    FROM requests
-    ORDER BY created_at DESC
+	sqlQuery := `
+    SELECT r.id, r.method, r.scheme, r.host, r.path, r.query_params, r.headers, r.cookies, r.post_params, r.body, r.created_at,
+      r.tls_connection_id, COALESCE(t.ja3_hash, ''), COALESCE(t.ja4, ''), r.protocol, r.pseudo_headers, r.trailers
+    FROM requests r
+    LEFT JOIN tls_connections t ON t.id = r.tls_connection_id
+    ` + where + `
+    ORDER BY r.created_at DESC
     `
 
-	rows, err := pool.Query(ctx, sqlQuery)
+	rows, err := pool.Query(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("GetAllRequests query: %w", err)
+		return nil, fmt.Errorf("%s query: %w", caller, err)
 	}
 	defer rows.Close()
 
@@ -160,6 +306,8 @@ func GetAllRequests() ([]RequestInfo, error) {
 		err := rows.Scan(
 			&req.ID,
 			&req.Method,
+			&req.Scheme,
+			&req.Host,
 			&req.Path,
 			&req.Query,
 			&req.Headers,
@@ -167,9 +315,15 @@ func GetAllRequests() ([]RequestInfo, error) {
 			&req.PostParams,
 			&req.Body,
 			&req.CreatedAt,
+			&req.TLSConnectionID,
+			&req.JA3Hash,
+			&req.JA4,
+			&req.Protocol,
+			&req.PseudoHeaders,
+			&req.Trailers,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("GetAllRequests scan: %w", err)
+			return nil, fmt.Errorf("%s scan: %w", caller, err)
 		}
 		requests = append(requests, req)
 	}
@@ -181,12 +335,12 @@ func GetRequestByID(id int) (*http.Request, error) {
 	ctx := context.Background()
 
 	const sqlQuery = `
-    SELECT method, path, query_params, headers, cookies, post_params, body
+    SELECT method, scheme, host, path, query_params, headers, cookies, post_params, body
     FROM requests WHERE id = $1
     `
-	var method, path, queryParams, headers, cookies, postParams, body string
+	var method, scheme, host, path, queryParams, headers, cookies, postParams, body string
 	row := pool.QueryRow(ctx, sqlQuery, id)
-	if err := row.Scan(&method, &path, &queryParams, &headers, &cookies, &postParams, &body); err != nil {
+	if err := row.Scan(&method, &scheme, &host, &path, &queryParams, &headers, &cookies, &postParams, &body); err != nil {
 		return nil, fmt.Errorf("GetRequestByID scan: %w", err)
 	}
 
@@ -195,28 +349,24 @@ func GetRequestByID(id int) (*http.Request, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse URL path: %w", err)
 	}
+	u.Scheme = scheme
+	u.Host = host
 
-	// Unmarshal query parameters into a map
-	var queryValues map[string]interface{}
-	if err := json.Unmarshal([]byte(queryParams), &queryValues); err != nil {
+	// Convert stored query parameters to url.Values and set them in the URL
+	values, err := jsonToValues(queryParams)
+	if err != nil {
 		return nil, fmt.Errorf("unmarshal query params: %w", err)
 	}
+	u.RawQuery = values.Encode()
 
-	// Convert the map to url.Values and set them in the URL
-	values := url.Values{}
-	for k, v := range queryValues {
-		switch vv := v.(type) {
-		case string:
-			values.Add(k, vv)
-		case []interface{}:
-			for _, item := range vv {
-				if s, ok := item.(string); ok {
-					values.Add(k, s)
-				}
-			}
+	// Form bodies are stored as post_params only, so rebuild them
+	if body == "" {
+		form, err := jsonToValues(postParams)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal post params: %w", err)
 		}
+		body = form.Encode()
 	}
-	u.RawQuery = values.Encode()
 
 	// Unmarshal headers
 	var headerValues http.Header
@@ -229,7 +379,88 @@ func GetRequestByID(id int) (*http.Request, error) {
 		URL:    u,
 		Header: headerValues,
 		Body:   io.NopCloser(strings.NewReader(body)),
+		Host:   host,
 	}
 
 	return req, nil
 }
+
+// jsonToValues converts a stored query_params/post_params object back into url.Values.
+func jsonToValues(raw string) (url.Values, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &m); err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	for k, v := range m {
+		switch vv := v.(type) {
+		case string:
+			values.Add(k, vv)
+		case []interface{}:
+			for _, item := range vv {
+				if s, ok := item.(string); ok {
+					values.Add(k, s)
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+type ResponseInfo struct {
+	ID            int             `json:"id"`
+	RequestID     int             `json:"request_id"`
+	StatusCode    int             `json:"status_code"`
+	StatusMessage string          `json:"status_message"`
+	Headers       json.RawMessage `json:"headers"`
+	Body          string          `json:"body"`
+	Protocol      string          `json:"protocol"`
+	PseudoHeaders json.RawMessage `json:"pseudo_headers"`
+	Trailers      json.RawMessage `json:"trailers"`
+	Streamed      bool            `json:"streamed"`
+	Truncated     bool            `json:"truncated"`
+}
+
+func GetResponseByID(id int) (*ResponseInfo, error) {
+	ctx := context.Background()
+
+	const sqlQuery = `
+    SELECT id, request_id, status_code, status_message, headers, body, protocol, pseudo_headers, trailers, streamed, truncated
+    FROM responses WHERE id = $1
+    `
+	var resp ResponseInfo
+	row := pool.QueryRow(ctx, sqlQuery, id)
+	if err := row.Scan(&resp.ID, &resp.RequestID, &resp.StatusCode, &resp.StatusMessage, &resp.Headers, &resp.Body,
+		&resp.Protocol, &resp.PseudoHeaders, &resp.Trailers, &resp.Streamed, &resp.Truncated); err != nil {
+		return nil, fmt.Errorf("GetResponseByID scan: %w", err)
+	}
+	return &resp, nil
+}
+
+// GetResponseByRequestID returns the latest response stored for a request.
+func GetResponseByRequestID(requestID int) (*ResponseInfo, error) {
+	ctx := context.Background()
+
+	const sqlQuery = `
+    SELECT id, request_id, status_code, status_message, headers, body, protocol, pseudo_headers, trailers, streamed, truncated
+    FROM responses WHERE request_id = $1
+    ORDER BY id DESC LIMIT 1
+    `
+	var resp ResponseInfo
+	row := pool.QueryRow(ctx, sqlQuery, requestID)
+	if err := row.Scan(&resp.ID, &resp.RequestID, &resp.StatusCode, &resp.StatusMessage, &resp.Headers, &resp.Body,
+		&resp.Protocol, &resp.PseudoHeaders, &resp.Trailers, &resp.Streamed, &resp.Truncated); err != nil {
+		return nil, fmt.Errorf("GetResponseByRequestID scan: %w", err)
+	}
+	return &resp, nil
+}
+
+// DeleteRequest removes a stored request; its responses go with it.
+func DeleteRequest(id int) error {
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx, `DELETE FROM requests WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("DeleteRequest exec: %w", err)
+	}
+	return nil
+}
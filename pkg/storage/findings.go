@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	StatusOpen          = "open"
+	StatusFalsePositive = "false-positive"
+	StatusFixed         = "fixed"
+)
+
+// Severities in ascending order.
+var Severities = []string{"info", "low", "medium", "high", "critical"}
+
+type Finding struct {
+	ID          int       `json:"id"`
+	Source      string    `json:"source"`
+	Type        string    `json:"type"`
+	Severity    string    `json:"severity"`
+	Confidence  string    `json:"confidence"`
+	Host        string    `json:"host"`
+	Path        string    `json:"path"`
+	Parameter   string    `json:"parameter"`
+	Detail      string    `json:"detail"`
+	Evidence    string    `json:"evidence"`
+	RequestID   int       `json:"request_id"`
+	ResponseID  int       `json:"response_id"`
+	Status      string    `json:"status"`
+	Occurrences int       `json:"occurrences"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// FindingFilter narrows ListFindings; zero fields match everything.
+type FindingFilter struct {
+	Host        string
+	MinSeverity string
+	Status      string
+	Type        string
+	RequestID   int
+}
+
+// SeverityRank returns the position of s in Severities, or -1.
+func SeverityRank(s string) int {
+	for i, sev := range Severities {
+		if sev == strings.ToLower(s) {
+			return i
+		}
+	}
+	return -1
+}
+
+func ValidStatus(s string) bool {
+	return s == StatusOpen || s == StatusFalsePositive || s == StatusFixed
+}
+
+// DedupKey identifies the same issue across repeated scans.
+func (f *Finding) DedupKey() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{f.Type, f.Host, f.Path, f.Parameter}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveFinding inserts f or, if the same issue was already reported, bumps
+// its occurrence counter and points evidence at the latest exchange.
+// Every exchange that showed the issue is kept as an occurrence. Findings
+// marked fixed are reopened, which is logged; false positives stay
+// suppressed.
+func SaveFinding(f *Finding) (int, error) {
+	ctx := context.Background()
+
+	if f.Confidence == "" {
+		f.Confidence = "firm"
+	}
+
+	// old is read before the upsert, so it holds the status the finding
+	// had, if any
+	const sqlUpsert = `
+    WITH old AS (
+      SELECT status FROM findings WHERE dedup_key = $1
+    ), saved AS (
+      INSERT INTO findings
+        (dedup_key, source, type, severity, confidence, host, path, parameter, detail, evidence, request_id, response_id)
+      VALUES
+        ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, 0), NULLIF($12, 0))
+      ON CONFLICT (dedup_key) DO UPDATE SET
+        severity    = EXCLUDED.severity,
+        confidence  = EXCLUDED.confidence,
+        detail      = EXCLUDED.detail,
+        evidence    = EXCLUDED.evidence,
+        response_id = CASE WHEN EXCLUDED.request_id IS NULL THEN findings.response_id ELSE EXCLUDED.response_id END,
+        request_id  = COALESCE(EXCLUDED.request_id, findings.request_id),
+        occurrences = findings.occurrences + 1,
+        last_seen   = NOW(),
+        status      = CASE WHEN findings.status = 'fixed' THEN 'open' ELSE findings.status END
+      RETURNING id, status
+    ), occurrence AS (
+      INSERT INTO finding_occurrences (finding_id, request_id, response_id)
+      SELECT id, $11, NULLIF($12, 0) FROM saved WHERE $11 <> 0
+    )
+    SELECT id, status, COALESCE((SELECT status FROM old), '') FROM saved
+    `
+	var id int
+	var status, oldStatus string
+	row := pool.QueryRow(ctx, sqlUpsert,
+		f.DedupKey(),
+		f.Source,
+		f.Type,
+		f.Severity,
+		f.Confidence,
+		f.Host,
+		f.Path,
+		f.Parameter,
+		f.Detail,
+		f.Evidence,
+		f.RequestID,
+		f.ResponseID,
+	)
+	if err := row.Scan(&id, &status, &oldStatus); err != nil {
+		return 0, fmt.Errorf("SaveFinding scan: %w", err)
+	}
+	if oldStatus == StatusFixed {
+		log.Printf("Finding #%d (%s on %s%s) seen again after being fixed, reopened", id, f.Type, f.Host, f.Path)
+	}
+	f.ID, f.Status = id, status
+	return id, nil
+}
+
+const findingColumns = `
+    id, source, type, severity, confidence, host, path, parameter, detail, evidence,
+    COALESCE(request_id, 0), COALESCE(response_id, 0), status, occurrences, first_seen, last_seen
+    `
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFinding(row rowScanner) (Finding, error) {
+	var f Finding
+	err := row.Scan(
+		&f.ID,
+		&f.Source,
+		&f.Type,
+		&f.Severity,
+		&f.Confidence,
+		&f.Host,
+		&f.Path,
+		&f.Parameter,
+		&f.Detail,
+		&f.Evidence,
+		&f.RequestID,
+		&f.ResponseID,
+		&f.Status,
+		&f.Occurrences,
+		&f.FirstSeen,
+		&f.LastSeen,
+	)
+	return f, err
+}
+
+func ListFindings(filter FindingFilter) ([]Finding, error) {
+	ctx := context.Background()
+
+	var conds []string
+	var args []any
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Host != "" {
+		addCond("host = $%d", filter.Host)
+	}
+	if filter.Status != "" {
+		addCond("status = $%d", filter.Status)
+	}
+	if filter.Type != "" {
+		addCond("type = $%d", filter.Type)
+	}
+	if filter.RequestID != 0 {
+		addCond("id IN (SELECT finding_id FROM finding_occurrences WHERE request_id = $%d)", filter.RequestID)
+	}
+	if rank := SeverityRank(filter.MinSeverity); rank > 0 {
+		addCond("severity = ANY($%d)", Severities[rank:])
+	}
+
+	sqlQuery := "SELECT " + findingColumns + " FROM findings"
+	if len(conds) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conds, " AND ")
+	}
+	sqlQuery += " ORDER BY last_seen DESC"
+
+	rows, err := pool.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ListFindings query: %w", err)
+	}
+	defer rows.Close()
+
+	findings := []Finding{}
+	for rows.Next() {
+		f, err := scanFinding(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListFindings scan: %w", err)
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+func GetFindingByID(id int) (*Finding, error) {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, "SELECT "+findingColumns+" FROM findings WHERE id = $1", id)
+	f, err := scanFinding(row)
+	if err != nil {
+		return nil, fmt.Errorf("GetFindingByID scan: %w", err)
+	}
+	return &f, nil
+}
+
+func GetFindingsByRequestID(requestID int) ([]Finding, error) {
+	return ListFindings(FindingFilter{RequestID: requestID})
+}
+
+func UpdateFindingStatus(id int, status string) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `UPDATE findings SET status = $1 WHERE id = $2`, status, id)
+	if err != nil {
+		return fmt.Errorf("UpdateFindingStatus exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateFindingStatus: finding %d not found", id)
+	}
+	return nil
+}
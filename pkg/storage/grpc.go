@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GRPCMessage is one length-prefixed message of a captured gRPC call.
+// Data is stored decompressed when the call's grpc-encoding is known.
+type GRPCMessage struct {
+	ID         int       `json:"id"`
+	RequestID  int       `json:"request_id"`
+	Method     string    `json:"method"`    // /package.Service/Method
+	Direction  string    `json:"direction"` // request or response
+	Seq        int       `json:"seq"`       // position within its direction
+	Compressed bool      `json:"compressed"`
+	Data       []byte    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// GRPCDescriptorSet is a serialized FileDescriptorSet used to decode
+// messages, as written by protoc --descriptor_set_out --include_imports.
+type GRPCDescriptorSet struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Data      []byte    `json:"-"`
+	Size      int       `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func SaveGRPCMessage(m *GRPCMessage) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO grpc_messages (request_id, method, direction, seq, compressed, data)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    RETURNING id, created_at
+    `, m.RequestID, m.Method, m.Direction, m.Seq, m.Compressed, m.Data)
+	if err := row.Scan(&m.ID, &m.CreatedAt); err != nil {
+		return fmt.Errorf("SaveGRPCMessage scan: %w", err)
+	}
+	return nil
+}
+
+// ListGRPCMessages returns the messages of request #requestID, requests
+// first, each direction in order.
+func ListGRPCMessages(requestID int) ([]GRPCMessage, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `
+    SELECT id, request_id, method, direction, seq, compressed, data, created_at
+    FROM grpc_messages
+    WHERE request_id = $1
+    ORDER BY direction = 'response', seq
+    `, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("ListGRPCMessages query: %w", err)
+	}
+	defer rows.Close()
+
+	msgs := []GRPCMessage{}
+	for rows.Next() {
+		var m GRPCMessage
+		if err := rows.Scan(&m.ID, &m.RequestID, &m.Method, &m.Direction, &m.Seq, &m.Compressed, &m.Data, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ListGRPCMessages scan: %w", err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+func ListGRPCDescriptorSets() ([]GRPCDescriptorSet, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT id, name, data, created_at FROM grpc_descriptors ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListGRPCDescriptorSets query: %w", err)
+	}
+	defer rows.Close()
+
+	sets := []GRPCDescriptorSet{}
+	for rows.Next() {
+		var s GRPCDescriptorSet
+		if err := rows.Scan(&s.ID, &s.Name, &s.Data, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ListGRPCDescriptorSets scan: %w", err)
+		}
+		s.Size = len(s.Data)
+		sets = append(sets, s)
+	}
+	return sets, rows.Err()
+}
+
+func CreateGRPCDescriptorSet(s *GRPCDescriptorSet) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO grpc_descriptors (name, data)
+    VALUES ($1, $2)
+    RETURNING id, created_at
+    `, s.Name, s.Data)
+	if err := row.Scan(&s.ID, &s.CreatedAt); err != nil {
+		return fmt.Errorf("CreateGRPCDescriptorSet scan: %w", err)
+	}
+	s.Size = len(s.Data)
+	return nil
+}
+
+func DeleteGRPCDescriptorSet(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM grpc_descriptors WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteGRPCDescriptorSet exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteGRPCDescriptorSet: descriptor set %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+type ScanJob struct {
+	ID         int        `json:"id"`
+	RequestID  int        `json:"request_id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	Progress   int        `json:"progress"`
+	Findings   int        `json:"findings"`
+	Error      string     `json:"error"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+const jobColumns = `id, request_id, kind, status, progress, findings, error, created_at, started_at, finished_at`
+
+func scanJob(row rowScanner) (ScanJob, error) {
+	var j ScanJob
+	err := row.Scan(
+		&j.ID,
+		&j.RequestID,
+		&j.Kind,
+		&j.Status,
+		&j.Progress,
+		&j.Findings,
+		&j.Error,
+		&j.CreatedAt,
+		&j.StartedAt,
+		&j.FinishedAt,
+	)
+	return j, err
+}
+
+func CreateScanJob(requestID int, kind string) (*ScanJob, error) {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO scan_jobs (request_id, kind)
+    VALUES ($1, $2)
+    RETURNING `+jobColumns, requestID, kind)
+	j, err := scanJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("CreateScanJob scan: %w", err)
+	}
+	return &j, nil
+}
+
+func GetScanJob(id int) (*ScanJob, error) {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM scan_jobs WHERE id = $1`, id)
+	j, err := scanJob(row)
+	if err != nil {
+		return nil, fmt.Errorf("GetScanJob scan: %w", err)
+	}
+	return &j, nil
+}
+
+func ListScanJobs() ([]ScanJob, error) {
+	return queryScanJobs(`SELECT ` + jobColumns + ` FROM scan_jobs ORDER BY id DESC`)
+}
+
+// ListUnfinishedScanJobs returns queued and running jobs, oldest first.
+func ListUnfinishedScanJobs() ([]ScanJob, error) {
+	return queryScanJobs(`SELECT `+jobColumns+` FROM scan_jobs WHERE status IN ($1, $2) ORDER BY id`, JobQueued, JobRunning)
+}
+
+func queryScanJobs(sqlQuery string, args ...any) ([]ScanJob, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("queryScanJobs query: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []ScanJob{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("queryScanJobs scan: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// StartScanJob moves a queued job to running; it reports false if the job
+// was cancelled or picked up in the meantime.
+func StartScanJob(id int) (bool, error) {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE scan_jobs SET status = $1, started_at = NOW(), progress = 0
+    WHERE id = $2 AND status = $3
+    `, JobRunning, id, JobQueued)
+	if err != nil {
+		return false, fmt.Errorf("StartScanJob exec: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func UpdateScanJobProgress(id, progress int) error {
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx, `UPDATE scan_jobs SET progress = $1 WHERE id = $2`, progress, id); err != nil {
+		return fmt.Errorf("UpdateScanJobProgress exec: %w", err)
+	}
+	return nil
+}
+
+// FinishScanJob records the final state of a job unless it was cancelled.
+func FinishScanJob(id int, status string, findings int, errMsg string) error {
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx, `
+    UPDATE scan_jobs SET status = $1, findings = $2, error = $3, finished_at = NOW(),
+      progress = CASE WHEN $1 = 'done' THEN 100 ELSE progress END
+    WHERE id = $4 AND status <> 'cancelled'
+    `, status, findings, errMsg, id); err != nil {
+		return fmt.Errorf("FinishScanJob exec: %w", err)
+	}
+	return nil
+}
+
+// CancelScanJob marks a queued or running job as cancelled.
+func CancelScanJob(id int) (bool, error) {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE scan_jobs SET status = $1, finished_at = NOW()
+    WHERE id = $2 AND status IN ($3, $4)
+    `, JobCancelled, id, JobQueued, JobRunning)
+	if err != nil {
+		return false, fmt.Errorf("CancelScanJob exec: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// RequeueScanJobs resets jobs interrupted by a restart so they run again.
+func RequeueScanJobs() error {
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx, `
+    UPDATE scan_jobs SET status = $1, started_at = NULL, progress = 0 WHERE status = $2
+    `, JobQueued, JobRunning); err != nil {
+		return fmt.Errorf("RequeueScanJobs exec: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MapRule serves matching requests from local files (kind "local") or
+// sends them to another upstream (kind "remote").
+type MapRule struct {
+	ID        int               `json:"id"`
+	Name      string            `json:"name"`
+	Enabled   bool              `json:"enabled"`
+	Kind      string            `json:"kind"`
+	Host      string            `json:"host"`
+	Path      string            `json:"path"`
+	Method    string            `json:"method"`
+	LocalPath string            `json:"local_path"` // file or directory, map-local only
+	Status    int               `json:"status"`     // map-local only
+	Headers   map[string]string `json:"headers"`    // map-local only
+	Target    string            `json:"target"`     // scheme://host[:port], map-remote only
+}
+
+const mapRuleColumns = `id, name, enabled, kind, host, path, method, local_path, status, headers, target`
+
+func scanMapRule(row rowScanner) (MapRule, error) {
+	var r MapRule
+	var headers []byte
+	err := row.Scan(&r.ID, &r.Name, &r.Enabled, &r.Kind, &r.Host, &r.Path, &r.Method, &r.LocalPath, &r.Status, &headers, &r.Target)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(headers, &r.Headers); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func ListMapRules() ([]MapRule, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT `+mapRuleColumns+` FROM map_rules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListMapRules query: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []MapRule{}
+	for rows.Next() {
+		r, err := scanMapRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListMapRules scan: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func GetMapRule(id int) (*MapRule, error) {
+	ctx := context.Background()
+
+	r, err := scanMapRule(pool.QueryRow(ctx, `SELECT `+mapRuleColumns+` FROM map_rules WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("GetMapRule scan: %w", err)
+	}
+	return &r, nil
+}
+
+func CreateMapRule(r *MapRule) error {
+	ctx := context.Background()
+
+	headers, _ := json.Marshal(r.Headers)
+	row := pool.QueryRow(ctx, `
+    INSERT INTO map_rules (name, enabled, kind, host, path, method, local_path, status, headers, target)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::jsonb, $10)
+    RETURNING id
+    `, r.Name, r.Enabled, r.Kind, r.Host, r.Path, r.Method, r.LocalPath, r.Status, string(headers), r.Target)
+	if err := row.Scan(&r.ID); err != nil {
+		return fmt.Errorf("CreateMapRule scan: %w", err)
+	}
+	return nil
+}
+
+func UpdateMapRule(r *MapRule) error {
+	ctx := context.Background()
+
+	headers, _ := json.Marshal(r.Headers)
+	tag, err := pool.Exec(ctx, `
+    UPDATE map_rules SET name = $1, enabled = $2, kind = $3, host = $4, path = $5, method = $6,
+      local_path = $7, status = $8, headers = $9::jsonb, target = $10
+    WHERE id = $11
+    `, r.Name, r.Enabled, r.Kind, r.Host, r.Path, r.Method, r.LocalPath, r.Status, string(headers), r.Target, r.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateMapRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateMapRule: rule %d not found", r.ID)
+	}
+	return nil
+}
+
+func DeleteMapRule(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM map_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteMapRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteMapRule: rule %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Mock answers matching requests with a canned response instead of
+// contacting upstream. RequestID points at the capture it was made from.
+type Mock struct {
+	ID        int         `json:"id"`
+	Name      string      `json:"name"`
+	Enabled   bool        `json:"enabled"`
+	RequestID int         `json:"request_id,omitempty"`
+	Method    string      `json:"method"`
+	Host      string      `json:"host"`
+	Path      string      `json:"path"`
+	Query     string      `json:"query"`      // params that must be present, url-encoded
+	MatchBody string      `json:"match_body"` // request body to match, empty matches any
+	Status    int         `json:"status"`
+	Headers   http.Header `json:"headers"`
+	Body      string      `json:"body"`
+	Template  bool        `json:"template"` // render Body with text/template
+}
+
+const mockColumns = `id, name, enabled, COALESCE(request_id, 0), method, host, path, query, match_body, status, headers, body, template`
+
+func scanMock(row rowScanner) (Mock, error) {
+	var m Mock
+	var headers []byte
+	err := row.Scan(&m.ID, &m.Name, &m.Enabled, &m.RequestID, &m.Method, &m.Host, &m.Path, &m.Query,
+		&m.MatchBody, &m.Status, &headers, &m.Body, &m.Template)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(headers, &m.Headers); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func ListMocks() ([]Mock, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT `+mockColumns+` FROM mocks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListMocks query: %w", err)
+	}
+	defer rows.Close()
+
+	mocks := []Mock{}
+	for rows.Next() {
+		m, err := scanMock(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListMocks scan: %w", err)
+		}
+		mocks = append(mocks, m)
+	}
+	return mocks, rows.Err()
+}
+
+func GetMock(id int) (*Mock, error) {
+	ctx := context.Background()
+
+	m, err := scanMock(pool.QueryRow(ctx, `SELECT `+mockColumns+` FROM mocks WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("GetMock scan: %w", err)
+	}
+	return &m, nil
+}
+
+func CreateMock(m *Mock) error {
+	ctx := context.Background()
+
+	headers, _ := json.Marshal(m.Headers)
+	row := pool.QueryRow(ctx, `
+    INSERT INTO mocks (name, enabled, request_id, method, host, path, query, match_body, status, headers, body, template)
+    VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, $8, $9, $10::jsonb, $11, $12)
+    RETURNING id
+    `, m.Name, m.Enabled, m.RequestID, m.Method, m.Host, m.Path, m.Query, m.MatchBody, m.Status, string(headers), m.Body, m.Template)
+	if err := row.Scan(&m.ID); err != nil {
+		return fmt.Errorf("CreateMock scan: %w", err)
+	}
+	return nil
+}
+
+func UpdateMock(m *Mock) error {
+	ctx := context.Background()
+
+	headers, _ := json.Marshal(m.Headers)
+	tag, err := pool.Exec(ctx, `
+    UPDATE mocks SET name = $1, enabled = $2, method = $3, host = $4, path = $5, query = $6,
+      match_body = $7, status = $8, headers = $9::jsonb, body = $10, template = $11
+    WHERE id = $12
+    `, m.Name, m.Enabled, m.Method, m.Host, m.Path, m.Query, m.MatchBody, m.Status, string(headers), m.Body, m.Template, m.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateMock exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateMock: mock %d not found", m.ID)
+	}
+	return nil
+}
+
+func DeleteMock(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM mocks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteMock exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteMock: mock %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// NetworkRule simulates a degraded network for matching traffic. Zero
+// values disable the corresponding effect.
+type NetworkRule struct {
+	ID           int     `json:"id"`
+	Name         string  `json:"name"`
+	Enabled      bool    `json:"enabled"`
+	Host         string  `json:"host"`
+	Path         string  `json:"path"`   // intercepted traffic only
+	Method       string  `json:"method"` // intercepted traffic only
+	LatencyMS    int     `json:"latency_ms"`
+	JitterMS     int     `json:"jitter_ms"`
+	UploadKbps   int     `json:"upload_kbps"`
+	DownloadKbps int     `json:"download_kbps"`
+	ResetRate    float64 `json:"reset_rate"` // 0..1
+	ErrorRate    float64 `json:"error_rate"` // 0..1
+	ErrorStatus  int     `json:"error_status"`
+}
+
+const networkRuleColumns = `id, name, enabled, host, path, method, latency_ms, jitter_ms, upload_kbps, download_kbps, reset_rate, error_rate, error_status`
+
+func scanNetworkRule(row rowScanner) (NetworkRule, error) {
+	var r NetworkRule
+	err := row.Scan(&r.ID, &r.Name, &r.Enabled, &r.Host, &r.Path, &r.Method, &r.LatencyMS, &r.JitterMS,
+		&r.UploadKbps, &r.DownloadKbps, &r.ResetRate, &r.ErrorRate, &r.ErrorStatus)
+	return r, err
+}
+
+func ListNetworkRules() ([]NetworkRule, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT `+networkRuleColumns+` FROM network_rules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListNetworkRules query: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []NetworkRule{}
+	for rows.Next() {
+		r, err := scanNetworkRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListNetworkRules scan: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func GetNetworkRule(id int) (*NetworkRule, error) {
+	ctx := context.Background()
+
+	r, err := scanNetworkRule(pool.QueryRow(ctx, `SELECT `+networkRuleColumns+` FROM network_rules WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("GetNetworkRule scan: %w", err)
+	}
+	return &r, nil
+}
+
+func CreateNetworkRule(r *NetworkRule) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO network_rules (name, enabled, host, path, method, latency_ms, jitter_ms,
+      upload_kbps, download_kbps, reset_rate, error_rate, error_status)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+    RETURNING id
+    `, r.Name, r.Enabled, r.Host, r.Path, r.Method, r.LatencyMS, r.JitterMS,
+		r.UploadKbps, r.DownloadKbps, r.ResetRate, r.ErrorRate, r.ErrorStatus)
+	if err := row.Scan(&r.ID); err != nil {
+		return fmt.Errorf("CreateNetworkRule scan: %w", err)
+	}
+	return nil
+}
+
+func UpdateNetworkRule(r *NetworkRule) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE network_rules SET name = $1, enabled = $2, host = $3, path = $4, method = $5,
+      latency_ms = $6, jitter_ms = $7, upload_kbps = $8, download_kbps = $9,
+      reset_rate = $10, error_rate = $11, error_status = $12
+    WHERE id = $13
+    `, r.Name, r.Enabled, r.Host, r.Path, r.Method, r.LatencyMS, r.JitterMS,
+		r.UploadKbps, r.DownloadKbps, r.ResetRate, r.ErrorRate, r.ErrorStatus, r.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateNetworkRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateNetworkRule: rule %d not found", r.ID)
+	}
+	return nil
+}
+
+func DeleteNetworkRule(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM network_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteNetworkRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteNetworkRule: rule %d not found", id)
+	}
+	return nil
+}
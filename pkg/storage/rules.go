@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// RewriteRule is a match-and-replace rule applied to traffic in flight.
+type RewriteRule struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Phase   string `json:"phase"`  // request or response
+	Host    string `json:"host"`   // glob
+	Path    string `json:"path"`   // regexp
+	Method  string `json:"method"` // exact
+	Action  string `json:"action"`
+	Header  string `json:"header"`
+	Match   string `json:"match"` // regexp
+	Value   string `json:"value"`
+}
+
+const ruleColumns = `id, name, enabled, phase, host, path, method, action, header, match, value`
+
+func scanRewriteRule(row rowScanner) (RewriteRule, error) {
+	var r RewriteRule
+	err := row.Scan(&r.ID, &r.Name, &r.Enabled, &r.Phase, &r.Host, &r.Path, &r.Method, &r.Action, &r.Header, &r.Match, &r.Value)
+	return r, err
+}
+
+func ListRewriteRules() ([]RewriteRule, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT `+ruleColumns+` FROM rewrite_rules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListRewriteRules query: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []RewriteRule{}
+	for rows.Next() {
+		r, err := scanRewriteRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListRewriteRules scan: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func GetRewriteRule(id int) (*RewriteRule, error) {
+	ctx := context.Background()
+
+	r, err := scanRewriteRule(pool.QueryRow(ctx, `SELECT `+ruleColumns+` FROM rewrite_rules WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("GetRewriteRule scan: %w", err)
+	}
+	return &r, nil
+}
+
+func CreateRewriteRule(r *RewriteRule) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO rewrite_rules (name, enabled, phase, host, path, method, action, header, match, value)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    RETURNING id
+    `, r.Name, r.Enabled, r.Phase, r.Host, r.Path, r.Method, r.Action, r.Header, r.Match, r.Value)
+	if err := row.Scan(&r.ID); err != nil {
+		return fmt.Errorf("CreateRewriteRule scan: %w", err)
+	}
+	return nil
+}
+
+func UpdateRewriteRule(r *RewriteRule) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE rewrite_rules SET name = $1, enabled = $2, phase = $3, host = $4, path = $5,
+      method = $6, action = $7, header = $8, match = $9, value = $10
+    WHERE id = $11
+    `, r.Name, r.Enabled, r.Phase, r.Host, r.Path, r.Method, r.Action, r.Header, r.Match, r.Value, r.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateRewriteRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateRewriteRule: rule %d not found", r.ID)
+	}
+	return nil
+}
+
+func DeleteRewriteRule(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM rewrite_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteRewriteRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteRewriteRule: rule %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// ScopeRule includes traffic in or excludes it from capture. All set
+// conditions of a rule must match.
+type ScopeRule struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Kind        string `json:"kind"`         // include or exclude
+	Host        string `json:"host"`         // glob
+	Path        string `json:"path"`         // regexp
+	Extensions  string `json:"extensions"`   // comma separated, e.g. "png,woff2"
+	ContentType string `json:"content_type"` // response Content-Type prefix, e.g. "image/"
+	PassThrough bool   `json:"pass_through"` // tunnel excluded HTTPS hosts without MITM
+}
+
+const scopeRuleColumns = `id, name, enabled, kind, host, path, extensions, content_type, pass_through`
+
+func scanScopeRule(row rowScanner) (ScopeRule, error) {
+	var r ScopeRule
+	err := row.Scan(&r.ID, &r.Name, &r.Enabled, &r.Kind, &r.Host, &r.Path, &r.Extensions, &r.ContentType, &r.PassThrough)
+	return r, err
+}
+
+func ListScopeRules() ([]ScopeRule, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT `+scopeRuleColumns+` FROM scope_rules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListScopeRules query: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []ScopeRule{}
+	for rows.Next() {
+		r, err := scanScopeRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListScopeRules scan: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func GetScopeRule(id int) (*ScopeRule, error) {
+	ctx := context.Background()
+
+	r, err := scanScopeRule(pool.QueryRow(ctx, `SELECT `+scopeRuleColumns+` FROM scope_rules WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("GetScopeRule scan: %w", err)
+	}
+	return &r, nil
+}
+
+func CreateScopeRule(r *ScopeRule) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO scope_rules (name, enabled, kind, host, path, extensions, content_type, pass_through)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    RETURNING id
+    `, r.Name, r.Enabled, r.Kind, r.Host, r.Path, r.Extensions, r.ContentType, r.PassThrough)
+	if err := row.Scan(&r.ID); err != nil {
+		return fmt.Errorf("CreateScopeRule scan: %w", err)
+	}
+	return nil
+}
+
+func UpdateScopeRule(r *ScopeRule) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE scope_rules SET name = $1, enabled = $2, kind = $3, host = $4, path = $5,
+      extensions = $6, content_type = $7, pass_through = $8
+    WHERE id = $9
+    `, r.Name, r.Enabled, r.Kind, r.Host, r.Path, r.Extensions, r.ContentType, r.PassThrough, r.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateScopeRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateScopeRule: rule %d not found", r.ID)
+	}
+	return nil
+}
+
+func DeleteScopeRule(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM scope_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteScopeRule exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteScopeRule: rule %d not found", id)
+	}
+	return nil
+}
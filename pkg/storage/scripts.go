@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Script is a Starlark program with onRequest/onResponse hooks.
+type Script struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Source    string `json:"source"`
+	TimeoutMS int    `json:"timeout_ms"`
+}
+
+const scriptColumns = `id, name, enabled, source, timeout_ms`
+
+func scanScript(row rowScanner) (Script, error) {
+	var s Script
+	err := row.Scan(&s.ID, &s.Name, &s.Enabled, &s.Source, &s.TimeoutMS)
+	return s, err
+}
+
+func ListScripts() ([]Script, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT `+scriptColumns+` FROM scripts ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListScripts query: %w", err)
+	}
+	defer rows.Close()
+
+	scripts := []Script{}
+	for rows.Next() {
+		s, err := scanScript(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListScripts scan: %w", err)
+		}
+		scripts = append(scripts, s)
+	}
+	return scripts, rows.Err()
+}
+
+func GetScript(id int) (*Script, error) {
+	ctx := context.Background()
+
+	s, err := scanScript(pool.QueryRow(ctx, `SELECT `+scriptColumns+` FROM scripts WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("GetScript scan: %w", err)
+	}
+	return &s, nil
+}
+
+func CreateScript(s *Script) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO scripts (name, enabled, source, timeout_ms)
+    VALUES ($1, $2, $3, $4)
+    RETURNING id
+    `, s.Name, s.Enabled, s.Source, s.TimeoutMS)
+	if err := row.Scan(&s.ID); err != nil {
+		return fmt.Errorf("CreateScript scan: %w", err)
+	}
+	return nil
+}
+
+func UpdateScript(s *Script) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `
+    UPDATE scripts SET name = $1, enabled = $2, source = $3, timeout_ms = $4
+    WHERE id = $5
+    `, s.Name, s.Enabled, s.Source, s.TimeoutMS, s.ID)
+	if err != nil {
+		return fmt.Errorf("UpdateScript exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("UpdateScript: script %d not found", s.ID)
+	}
+	return nil
+}
+
+func DeleteScript(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM scripts WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeleteScript exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeleteScript: script %d not found", id)
+	}
+	return nil
+}
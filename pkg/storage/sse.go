@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SSEEvent is one Server-Sent Event of a captured event stream, stored as
+// it arrived.
+type SSEEvent struct {
+	ID        int       `json:"id"`
+	RequestID int       `json:"request_id"`
+	EventID   string    `json:"event_id"` // last event ID in effect
+	Type      string    `json:"type"`     // empty for "message"
+	Data      string    `json:"data"`
+	Retry     *int      `json:"retry,omitempty"` // reconnection time in ms, if set
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func SaveSSEEvent(e *SSEEvent) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO sse_events (request_id, event_id, type, data, retry)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING id, created_at
+    `, e.RequestID, e.EventID, e.Type, textBody([]byte(e.Data)), e.Retry)
+	if err := row.Scan(&e.ID, &e.CreatedAt); err != nil {
+		return fmt.Errorf("SaveSSEEvent scan: %w", err)
+	}
+	return nil
+}
+
+// ListSSEEvents returns the events of the stream answering request
+// #requestID in the order they arrived.
+func ListSSEEvents(requestID int) ([]SSEEvent, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `
+    SELECT id, request_id, event_id, type, data, retry, created_at
+    FROM sse_events
+    WHERE request_id = $1
+    ORDER BY id
+    `, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("ListSSEEvents query: %w", err)
+	}
+	defer rows.Close()
+
+	events := []SSEEvent{}
+	for rows.Next() {
+		var e SSEEvent
+		if err := rows.Scan(&e.ID, &e.RequestID, &e.EventID, &e.Type, &e.Data, &e.Retry, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ListSSEEvents scan: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
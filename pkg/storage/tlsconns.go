@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TLSConnection records the client side of an intercepted HTTPS
+// connection: what the client offered in its ClientHello and what was
+// negotiated. Requests sent over it point back to it.
+type TLSConnection struct {
+	ID              int       `json:"id"`
+	Host            string    `json:"host"` // CONNECT target
+	SNI             string    `json:"sni"`
+	Version         string    `json:"version"`
+	CipherSuite     string    `json:"cipher_suite"`
+	ALPN            string    `json:"alpn"`
+	OfferedVersions []string  `json:"offered_versions"`
+	OfferedCiphers  []string  `json:"offered_ciphers"`
+	OfferedALPN     []string  `json:"offered_alpn"`
+	JA3             string    `json:"ja3"`
+	JA3Hash         string    `json:"ja3_hash"`
+	JA4             string    `json:"ja4"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+const tlsConnectionColumns = `id, host, sni, version, cipher_suite, alpn, offered_versions, offered_ciphers, offered_alpn, ja3, ja3_hash, ja4, created_at`
+
+func scanTLSConnection(row rowScanner) (TLSConnection, error) {
+	var c TLSConnection
+	err := row.Scan(&c.ID, &c.Host, &c.SNI, &c.Version, &c.CipherSuite, &c.ALPN,
+		&c.OfferedVersions, &c.OfferedCiphers, &c.OfferedALPN, &c.JA3, &c.JA3Hash, &c.JA4, &c.CreatedAt)
+	return c, err
+}
+
+func SaveTLSConnection(c *TLSConnection) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO tls_connections (host, sni, version, cipher_suite, alpn, offered_versions, offered_ciphers, offered_alpn, ja3, ja3_hash, ja4)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+    RETURNING id, created_at
+    `, c.Host, c.SNI, c.Version, c.CipherSuite, c.ALPN, nonNil(c.OfferedVersions), nonNil(c.OfferedCiphers), nonNil(c.OfferedALPN),
+		c.JA3, c.JA3Hash, c.JA4)
+	if err := row.Scan(&c.ID, &c.CreatedAt); err != nil {
+		return fmt.Errorf("SaveTLSConnection scan: %w", err)
+	}
+	return nil
+}
+
+// SetRequestTLSConnection links request #requestID to the TLS connection
+// it was sent over.
+func SetRequestTLSConnection(requestID, connID int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `UPDATE requests SET tls_connection_id = $2 WHERE id = $1`, requestID, connID)
+	if err != nil {
+		return fmt.Errorf("SetRequestTLSConnection exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("SetRequestTLSConnection: request %d not found", requestID)
+	}
+	return nil
+}
+
+func GetTLSConnection(id int) (*TLSConnection, error) {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `SELECT `+tlsConnectionColumns+` FROM tls_connections WHERE id = $1`, id)
+	c, err := scanTLSConnection(row)
+	if err != nil {
+		return nil, fmt.Errorf("GetTLSConnection scan: %w", err)
+	}
+	return &c, nil
+}
+
+// ListTLSConnections returns the latest connections, optionally only
+// those whose SNI or CONNECT host is host.
+func ListTLSConnections(host string, limit int) ([]TLSConnection, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `
+    SELECT `+tlsConnectionColumns+`
+    FROM tls_connections
+    WHERE $1 = '' OR sni = $1 OR host = $1 OR host LIKE $1 || ':%'
+    ORDER BY id DESC
+    LIMIT $2
+    `, host, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListTLSConnections query: %w", err)
+	}
+	defer rows.Close()
+
+	conns := []TLSConnection{}
+	for rows.Next() {
+		c, err := scanTLSConnection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ListTLSConnections scan: %w", err)
+		}
+		conns = append(conns, c)
+	}
+	return conns, rows.Err()
+}
+
+// nonNil keeps NOT NULL array columns from receiving NULL.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PassThroughHost is an HTTPS host glob that is tunnelled without MITM,
+// added by hand ("manual") or after repeated handshake failures ("auto").
+// Automatic entries stop applying at ExpiresAt.
+type PassThroughHost struct {
+	ID        int        `json:"id"`
+	Host      string     `json:"host"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Tunnel records a connection that was relayed without interception.
+type Tunnel struct {
+	ID         int       `json:"id"`
+	Host       string    `json:"host"`
+	SNI        string    `json:"sni"`
+	Reason     string    `json:"reason"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	BytesUp    int64     `json:"bytes_up"`
+	BytesDown  int64     `json:"bytes_down"`
+}
+
+func ListPassThroughHosts() ([]PassThroughHost, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT id, host, reason, expires_at, created_at FROM tls_passthrough ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("ListPassThroughHosts query: %w", err)
+	}
+	defer rows.Close()
+
+	hosts := []PassThroughHost{}
+	for rows.Next() {
+		var h PassThroughHost
+		if err := rows.Scan(&h.ID, &h.Host, &h.Reason, &h.ExpiresAt, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ListPassThroughHosts scan: %w", err)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, rows.Err()
+}
+
+// AddPassThroughHost stores h, replacing the reason and expiry of the
+// entry for the same host if there is one.
+func AddPassThroughHost(h *PassThroughHost) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO tls_passthrough (host, reason, expires_at)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (host) DO UPDATE SET reason = EXCLUDED.reason, expires_at = EXCLUDED.expires_at
+    RETURNING id, created_at
+    `, h.Host, h.Reason, h.ExpiresAt)
+	if err := row.Scan(&h.ID, &h.CreatedAt); err != nil {
+		return fmt.Errorf("AddPassThroughHost scan: %w", err)
+	}
+	return nil
+}
+
+func DeletePassThroughHost(id int) error {
+	ctx := context.Background()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM tls_passthrough WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("DeletePassThroughHost exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("DeletePassThroughHost: host %d not found", id)
+	}
+	return nil
+}
+
+func SaveTunnel(t *Tunnel) error {
+	ctx := context.Background()
+
+	row := pool.QueryRow(ctx, `
+    INSERT INTO tunnels (host, sni, reason, started_at, duration_ms, bytes_up, bytes_down)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    RETURNING id
+    `, t.Host, t.SNI, t.Reason, t.StartedAt, t.DurationMS, t.BytesUp, t.BytesDown)
+	if err := row.Scan(&t.ID); err != nil {
+		return fmt.Errorf("SaveTunnel scan: %w", err)
+	}
+	return nil
+}
+
+// ListTunnels returns the latest tunnels, optionally for one host only.
+func ListTunnels(host string, limit int) ([]Tunnel, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `
+    SELECT id, host, sni, reason, started_at, duration_ms, bytes_up, bytes_down
+    FROM tunnels
+    WHERE $1 = '' OR host = $1 OR host LIKE $1 || ':%'
+    ORDER BY id DESC
+    LIMIT $2
+    `, host, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ListTunnels query: %w", err)
+	}
+	defer rows.Close()
+
+	tunnels := []Tunnel{}
+	for rows.Next() {
+		var t Tunnel
+		if err := rows.Scan(&t.ID, &t.Host, &t.SNI, &t.Reason, &t.StartedAt, &t.DurationMS, &t.BytesUp, &t.BytesDown); err != nil {
+			return nil, fmt.Errorf("ListTunnels scan: %w", err)
+		}
+		tunnels = append(tunnels, t)
+	}
+	return tunnels, rows.Err()
+}
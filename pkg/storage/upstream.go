@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// UpstreamTLSConfig is the stored upstream trust and ClientHello
+// configuration; see upstream.Config.
+type UpstreamTLSConfig struct {
+	CABundle      string
+	InsecureHosts []string
+	Fingerprint   string
+}
+
+// GetUpstreamTLSConfig returns the stored configuration, or nil if none
+// was saved yet.
+func GetUpstreamTLSConfig() (*UpstreamTLSConfig, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `SELECT ca_bundle, insecure_hosts, fingerprint FROM upstream_tls_config`)
+	if err != nil {
+		return nil, fmt.Errorf("GetUpstreamTLSConfig query: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var c UpstreamTLSConfig
+	if err := rows.Scan(&c.CABundle, &c.InsecureHosts, &c.Fingerprint); err != nil {
+		return nil, fmt.Errorf("GetUpstreamTLSConfig scan: %w", err)
+	}
+	return &c, nil
+}
+
+// SaveUpstreamTLSConfig replaces the stored configuration.
+func SaveUpstreamTLSConfig(c *UpstreamTLSConfig) error {
+	ctx := context.Background()
+
+	_, err := pool.Exec(ctx, `
+    INSERT INTO upstream_tls_config (id, ca_bundle, insecure_hosts, fingerprint)
+    VALUES (TRUE, $1, $2, $3)
+    ON CONFLICT (id) DO UPDATE SET
+      ca_bundle      = EXCLUDED.ca_bundle,
+      insecure_hosts = EXCLUDED.insecure_hosts,
+      fingerprint    = EXCLUDED.fingerprint
+    `, c.CABundle, nonNil(c.InsecureHosts), c.Fingerprint)
+	if err != nil {
+		return fmt.Errorf("SaveUpstreamTLSConfig exec: %w", err)
+	}
+	return nil
+}
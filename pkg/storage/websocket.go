@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WebSocketMessage is one message relayed on a WebSocket connection. It
+// belongs to the request that opened the connection.
+type WebSocketMessage struct {
+	ID        int       `json:"id"`
+	RequestID int       `json:"request_id"`
+	Direction string    `json:"direction"` // client (to server) or server (to client)
+	Type      string    `json:"type"`      // text or binary
+	Data      []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func SaveWebSocketMessage(m *WebSocketMessage) error {
+	ctx := context.Background()
+
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	row := pool.QueryRow(ctx, `
+    INSERT INTO websocket_messages (request_id, direction, type, data, created_at)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING id
+    `, m.RequestID, m.Direction, m.Type, m.Data, m.CreatedAt)
+	if err := row.Scan(&m.ID); err != nil {
+		return fmt.Errorf("SaveWebSocketMessage scan: %w", err)
+	}
+	return nil
+}
+
+// ListWebSocketMessages returns the messages of the connection opened by
+// request #requestID in the order they were relayed.
+func ListWebSocketMessages(requestID int) ([]WebSocketMessage, error) {
+	ctx := context.Background()
+
+	rows, err := pool.Query(ctx, `
+    SELECT id, request_id, direction, type, data, created_at
+    FROM websocket_messages
+    WHERE request_id = $1
+    ORDER BY id
+    `, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("ListWebSocketMessages query: %w", err)
+	}
+	defer rows.Close()
+
+	msgs := []WebSocketMessage{}
+	for rows.Next() {
+		var m WebSocketMessage
+		if err := rows.Scan(&m.ID, &m.RequestID, &m.Direction, &m.Type, &m.Data, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ListWebSocketMessages scan: %w", err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
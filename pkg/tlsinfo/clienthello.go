@@ -0,0 +1,177 @@
+// Package tlsinfo parses TLS ClientHello messages off the wire, before
+// the connection is handed to crypto/tls.
+package tlsinfo
+
+import (
+	"bufio"
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+const (
+	recordTypeHandshake  = 22
+	handshakeClientHello = 1
+
+	extServerName          = 0
+	extSupportedGroups     = 10
+	extPointFormats        = 11
+	extSignatureAlgorithms = 13
+	extALPN                = 16
+	extSupportedVersions   = 43
+)
+
+// RecordSize is a bufio.Reader size that fits any single TLS record, for
+// use with Peek.
+const RecordSize = 5 + 1<<14
+
+var errMalformed = errors.New("tlsinfo: malformed ClientHello")
+
+// ClientHello holds the fields of a ClientHello we care about. Lists keep
+// the order the client sent them in, GREASE values included.
+type ClientHello struct {
+	Version             uint16 // legacy_version field
+	CipherSuites        []uint16
+	Extensions          []uint16
+	ServerName          string
+	SupportedGroups     []uint16
+	PointFormats        []uint8
+	SignatureAlgorithms []uint16
+	ALPN                []string
+	SupportedVersions   []uint16
+
+	// Raw is the whole TLS record the ClientHello came in; set by Peek.
+	Raw []byte
+}
+
+// Peek reads the ClientHello record at the head of r without consuming
+// it. It needs the whole record to fit in r's buffer.
+func Peek(r *bufio.Reader) (*ClientHello, error) {
+	hdr, err := r.Peek(5)
+	if err != nil {
+		return nil, err
+	}
+	if hdr[0] != recordTypeHandshake {
+		return nil, errors.New("tlsinfo: not a TLS handshake")
+	}
+	n := int(hdr[3])<<8 | int(hdr[4])
+	record, err := r.Peek(5 + n)
+	if err != nil {
+		return nil, err
+	}
+	hello, err := Parse(record[5:])
+	if err != nil {
+		return nil, err
+	}
+	hello.Raw = append([]byte(nil), record...)
+	return hello, nil
+}
+
+// Parse parses a ClientHello handshake message (the payload of the first
+// TLS record).
+func Parse(msg []byte) (*ClientHello, error) {
+	s := cryptobyte.String(msg)
+	var typ uint8
+	var body cryptobyte.String
+	if !s.ReadUint8(&typ) || typ != handshakeClientHello || !s.ReadUint24LengthPrefixed(&body) {
+		return nil, errMalformed
+	}
+
+	var version uint16
+	var random, sessionID, ciphers, compression []byte
+	if !body.ReadUint16(&version) ||
+		!body.ReadBytes(&random, 32) ||
+		!body.ReadUint8LengthPrefixed((*cryptobyte.String)(&sessionID)) ||
+		!body.ReadUint16LengthPrefixed((*cryptobyte.String)(&ciphers)) ||
+		!body.ReadUint8LengthPrefixed((*cryptobyte.String)(&compression)) {
+		return nil, errMalformed
+	}
+
+	hello := &ClientHello{Version: version}
+	if !readUint16s(ciphers, &hello.CipherSuites) {
+		return nil, errMalformed
+	}
+	if body.Empty() {
+		return hello, nil
+	}
+	var exts cryptobyte.String
+	if !body.ReadUint16LengthPrefixed(&exts) {
+		return nil, errMalformed
+	}
+	for !exts.Empty() {
+		var ext uint16
+		var data cryptobyte.String
+		if !exts.ReadUint16(&ext) || !exts.ReadUint16LengthPrefixed(&data) {
+			return nil, errMalformed
+		}
+		hello.Extensions = append(hello.Extensions, ext)
+		var ok bool
+		switch ext {
+		case extServerName:
+			ok = parseServerName(data, hello)
+		case extSupportedGroups:
+			var list cryptobyte.String
+			ok = data.ReadUint16LengthPrefixed(&list) && readUint16s(list, &hello.SupportedGroups)
+		case extPointFormats:
+			ok = data.ReadUint8LengthPrefixed((*cryptobyte.String)(&hello.PointFormats))
+		case extSignatureAlgorithms:
+			var list cryptobyte.String
+			ok = data.ReadUint16LengthPrefixed(&list) && readUint16s(list, &hello.SignatureAlgorithms)
+		case extALPN:
+			ok = parseALPN(data, hello)
+		case extSupportedVersions:
+			var list cryptobyte.String
+			ok = data.ReadUint8LengthPrefixed(&list) && readUint16s(list, &hello.SupportedVersions)
+		default:
+			ok = true
+		}
+		if !ok {
+			return nil, errMalformed
+		}
+	}
+	return hello, nil
+}
+
+func parseServerName(data cryptobyte.String, hello *ClientHello) bool {
+	var names cryptobyte.String
+	if !data.ReadUint16LengthPrefixed(&names) {
+		return false
+	}
+	for !names.Empty() {
+		var nameType uint8
+		var name []byte
+		if !names.ReadUint8(&nameType) || !names.ReadUint16LengthPrefixed((*cryptobyte.String)(&name)) {
+			return false
+		}
+		if nameType == 0 {
+			hello.ServerName = string(name)
+		}
+	}
+	return true
+}
+
+func parseALPN(data cryptobyte.String, hello *ClientHello) bool {
+	var protos cryptobyte.String
+	if !data.ReadUint16LengthPrefixed(&protos) {
+		return false
+	}
+	for !protos.Empty() {
+		var proto []byte
+		if !protos.ReadUint8LengthPrefixed((*cryptobyte.String)(&proto)) {
+			return false
+		}
+		hello.ALPN = append(hello.ALPN, string(proto))
+	}
+	return true
+}
+
+func readUint16s(s cryptobyte.String, out *[]uint16) bool {
+	for !s.Empty() {
+		var v uint16
+		if !s.ReadUint16(&v) {
+			return false
+		}
+		*out = append(*out, v)
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package tlsinfo
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// isGREASE reports whether v is one of the reserved values clients send to
+// keep servers tolerant of unknown ones (RFC 8701). Fingerprints skip them.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// JA3 returns the JA3 string of the ClientHello and its MD5 hash, the form
+// usually compared:
+//
+//	SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats
+func (h *ClientHello) JA3() (full, hash string) {
+	points := make([]string, len(h.PointFormats))
+	for i, p := range h.PointFormats {
+		points[i] = strconv.Itoa(int(p))
+	}
+	full = strings.Join([]string{
+		strconv.Itoa(int(h.Version)),
+		joinDecimal(h.CipherSuites),
+		joinDecimal(h.Extensions),
+		joinDecimal(h.SupportedGroups),
+		strings.Join(points, "-"),
+	}, ",")
+	sum := md5.Sum([]byte(full))
+	return full, hex.EncodeToString(sum[:])
+}
+
+// JA4 returns the JA4 fingerprint of a ClientHello received over TCP:
+// a readable prefix with the TLS version, SNI presence, counts and ALPN,
+// then truncated hashes of the sorted cipher suites and of the sorted
+// extensions plus signature algorithms.
+func (h *ClientHello) JA4() string {
+	ciphers := withoutGREASE(h.CipherSuites)
+	exts := withoutGREASE(h.Extensions)
+
+	sni := "i"
+	if h.ServerName != "" {
+		sni = "d"
+	}
+	a := fmt.Sprintf("t%s%s%02d%02d%s", ja4Version(h), sni, min(len(ciphers), 99), min(len(exts), 99), ja4ALPN(h.ALPN))
+
+	slices.Sort(ciphers)
+	b := ja4Hash(joinHex(ciphers))
+
+	var sorted []uint16
+	for _, e := range exts {
+		if e != extServerName && e != extALPN {
+			sorted = append(sorted, e)
+		}
+	}
+	slices.Sort(sorted)
+	c := joinHex(sorted)
+	if sigs := withoutGREASE(h.SignatureAlgorithms); len(sigs) > 0 {
+		c += "_" + joinHex(sigs)
+	}
+	if len(sorted) == 0 {
+		c = ""
+	}
+	return a + "_" + b + "_" + ja4Hash(c)
+}
+
+func ja4Version(h *ClientHello) string {
+	v := h.Version
+	for _, sv := range h.SupportedVersions {
+		if !isGREASE(sv) && sv > v {
+			v = sv
+		}
+	}
+	switch v {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+// ja4ALPN takes the first and last character of the first ALPN value, or
+// of its hex form when those aren't alphanumeric.
+func ja4ALPN(alpn []string) string {
+	if len(alpn) == 0 || alpn[0] == "" {
+		return "00"
+	}
+	p := alpn[0]
+	first, last := p[0], p[len(p)-1]
+	if isAlnum(first) && isAlnum(last) {
+		return string([]byte{first, last})
+	}
+	x := hex.EncodeToString([]byte(p))
+	return x[:1] + x[len(x)-1:]
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func ja4Hash(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func withoutGREASE(vs []uint16) []uint16 {
+	out := make([]uint16, 0, len(vs))
+	for _, v := range vs {
+		if !isGREASE(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func joinDecimal(vs []uint16) string {
+	parts := make([]string, 0, len(vs))
+	for _, v := range withoutGREASE(vs) {
+		parts = append(parts, strconv.Itoa(int(v)))
+	}
+	return strings.Join(parts, "-")
+}
+
+func joinHex(vs []uint16) string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		parts[i] = fmt.Sprintf("%04x", v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,90 @@
+package tlsinfo
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// hello returns the ClientHello utls sends for a browser profile.
+func hello(t *testing.T, id utls.ClientHelloID) *ClientHello {
+	t.Helper()
+	c, s := net.Pipe()
+	go func() {
+		utls.UClient(c, &utls.Config{ServerName: "example.com"}, id).Handshake()
+		c.Close()
+	}()
+	defer s.Close()
+	h, err := Peek(bufio.NewReaderSize(s, RecordSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// TestFingerprints checks JA3 and JA4 against the values published for
+// the browsers utls impersonates. Chrome shuffles its extensions since
+// version 110, so it has no stable JA3.
+func TestFingerprints(t *testing.T) {
+	tests := []struct {
+		id      utls.ClientHelloID
+		ja3Hash string
+		ja4     string
+	}{
+		{utls.HelloChrome_131, "", "t13d1516h2_8daaf6152771_02713d6af862"},
+		{utls.HelloEdge_106, "cd08e31494f9531f560d64c695473da9", "t13d1516h2_8daaf6152771_e5627efa2ab1"},
+		{utls.HelloFirefox_120, "b5001237acdf006056b409cc433726b0", "t13d1715h2_5b57614c22b0_5c2c66f702b0"},
+		{utls.HelloSafari_16_0, "773906b0efdefa24a7f2b8eb6985bf37", "t13d2014h2_a09f3c656075_14788d8d241b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id.Str(), func(t *testing.T) {
+			h := hello(t, tt.id)
+			if got := h.JA4(); got != tt.ja4 {
+				t.Errorf("JA4 = %s, want %s", got, tt.ja4)
+			}
+			if tt.ja3Hash == "" {
+				return
+			}
+			if full, hash := h.JA3(); hash != tt.ja3Hash {
+				t.Errorf("JA3 = %s (%s), want %s", hash, full, tt.ja3Hash)
+			}
+		})
+	}
+}
+
+// TestJA3 checks the example from the JA3 README.
+func TestJA3(t *testing.T) {
+	h := &ClientHello{
+		Version:         769,
+		CipherSuites:    []uint16{0x0a0a, 47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4},
+		Extensions:      []uint16{0, 10, 11},
+		SupportedGroups: []uint16{23, 24, 25},
+		PointFormats:    []uint8{0},
+	}
+	full, hash := h.JA3()
+	if want := "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0"; full != want {
+		t.Errorf("JA3 string = %s, want %s", full, want)
+	}
+	if want := "ada70206e40642a3e4461f35503241d5"; hash != want {
+		t.Errorf("JA3 hash = %s, want %s", hash, want)
+	}
+}
+
+func TestJA4ALPN(t *testing.T) {
+	tests := []struct {
+		alpn []string
+		want string
+	}{
+		{nil, "00"},
+		{[]string{"h2", "http/1.1"}, "h2"},
+		{[]string{"http/1.1"}, "h1"},
+		{[]string{"\xab"}, "ab"},
+	}
+	for _, tt := range tests {
+		if got := ja4ALPN(tt.alpn); got != tt.want {
+			t.Errorf("ja4ALPN(%q) = %s, want %s", tt.alpn, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package tlspass keeps the list of HTTPS hosts that are tunnelled without
+// interception, typically because the client pins certificates.
+//
+// Hosts are added by hand or automatically once clients reject the forged
+// certificate several times in a row. Only the alerts clients send when
+// they refuse a certificate count; other handshake failures (scanners,
+// protocol mismatches, dropped connections) say nothing about pinning.
+// Automatic entries expire, so that a host is intercepted again once the
+// client that refused it is gone. A failed handshake can't be retried on
+// the same connection, so detection only helps the connections that
+// follow.
+package tlspass
+
+import (
+	"errors"
+	"log"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+const (
+	ReasonManual = "manual"
+	ReasonAuto   = "auto"
+)
+
+// Rejections within Window needed to add a host automatically, and how
+// long such an entry lasts.
+var (
+	Threshold = 3
+	Window    = 10 * time.Minute
+	Expiry    = 24 * time.Hour
+)
+
+// rejections are the texts crypto/tls gives the alerts a client sends when
+// it refuses a certificate (bad_certificate, certificate_unknown and
+// unknown_ca); the alert type itself is unexported.
+var rejections = []string{
+	"tls: bad certificate",
+	"tls: unknown certificate",
+	"tls: unknown certificate authority",
+}
+
+type failures struct {
+	count int
+	first time.Time
+}
+
+var (
+	mu       sync.RWMutex
+	hosts    []storage.PassThroughHost
+	failedMu sync.Mutex
+	failed   = map[string]*failures{}
+)
+
+// Load replaces the list with the one in storage.
+func Load() error {
+	list, err := storage.ListPassThroughHosts()
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	hosts = list
+	mu.Unlock()
+	return nil
+}
+
+// Match reports whether host is on the list.
+func Match(host string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	now := time.Now()
+	for _, h := range hosts {
+		if h.ExpiresAt != nil && now.After(*h.ExpiresAt) {
+			continue
+		}
+		if match.Host(h.Host, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add stores host on the list. Automatic entries expire after Expiry.
+func Add(host, reason string) (*storage.PassThroughHost, error) {
+	h := &storage.PassThroughHost{Host: host, Reason: reason}
+	if reason == ReasonAuto {
+		expires := time.Now().Add(Expiry)
+		h.ExpiresAt = &expires
+	}
+	if err := storage.AddPassThroughHost(h); err != nil {
+		return nil, err
+	}
+	return h, Load()
+}
+
+// Remove deletes entry #id.
+func Remove(id int) error {
+	if err := storage.DeletePassThroughHost(id); err != nil {
+		return err
+	}
+	return Load()
+}
+
+// Handshake records the outcome of a client handshake for host. After
+// Threshold certificate rejections within Window, host is added to the
+// list; other failures are ignored.
+func Handshake(host string, err error) {
+	if err != nil && !rejected(err) {
+		return
+	}
+	failedMu.Lock()
+	if err == nil {
+		delete(failed, host)
+		failedMu.Unlock()
+		return
+	}
+	f := failed[host]
+	now := time.Now()
+	if f == nil || now.Sub(f.first) > Window {
+		f = &failures{first: now}
+		failed[host] = f
+	}
+	f.count++
+	add := f.count >= Threshold
+	if add {
+		delete(failed, host)
+	}
+	failedMu.Unlock()
+
+	if !add || Match(host) {
+		return
+	}
+	log.Printf("Client rejected the certificate for %s %d times, tunnelling it for %v", host, Threshold, Expiry)
+	if _, err := Add(host, ReasonAuto); err != nil {
+		log.Println("Error adding pass-through host:", err)
+	}
+}
+
+// rejected reports whether err is a client refusing the certificate.
+func rejected(err error) bool {
+	var op *net.OpError
+	if !errors.As(err, &op) || op.Op != "remote error" || op.Err == nil {
+		return false
+	}
+	return slices.Contains(rejections, op.Err.Error())
+}
@@ -0,0 +1,78 @@
+package tlspass
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// selfSigned returns a certificate for example.com that no client trusts.
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serverError runs a handshake against client and returns the server's
+// error.
+func serverError(t *testing.T, client func(net.Conn)) error {
+	t.Helper()
+	c, s := net.Pipe()
+	go func() {
+		client(c)
+		c.Close()
+	}()
+	defer s.Close()
+	return tls.Server(s, &tls.Config{Certificates: []tls.Certificate{selfSigned(t)}}).Handshake()
+}
+
+func TestRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		client func(net.Conn)
+		want   bool
+	}{
+		{"unknown CA", func(c net.Conn) {
+			tls.Client(c, &tls.Config{ServerName: "example.com"}).Handshake()
+		}, true},
+		{"wrong name", func(c net.Conn) {
+			tls.Client(c, &tls.Config{ServerName: "other.test"}).Handshake()
+		}, true},
+		{"no shared cipher suite", func(c net.Conn) {
+			tls.Client(c, &tls.Config{ServerName: "example.com", MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}}).Handshake()
+		}, false},
+		{"connection closed", func(c net.Conn) {}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := serverError(t, tt.client)
+			if err == nil {
+				t.Fatal("handshake succeeded")
+			}
+			if got := rejected(err); got != tt.want {
+				t.Errorf("rejected(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
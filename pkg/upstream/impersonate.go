@@ -0,0 +1,169 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// Values of Config.Fingerprint besides the browser profiles.
+const (
+	FingerprintGo     = ""       // crypto/tls defaults
+	FingerprintMirror = "mirror" // copy the client's ClientHello
+)
+
+// profiles are the browser ClientHellos Config.Fingerprint can name.
+var profiles = map[string]utls.ClientHelloID{
+	"chrome":     utls.HelloChrome_Auto,
+	"firefox":    utls.HelloFirefox_Auto,
+	"safari":     utls.HelloSafari_Auto,
+	"edge":       utls.HelloEdge_Auto,
+	"ios":        utls.HelloIOS_Auto,
+	"randomized": utls.HelloRandomizedNoALPN,
+}
+
+func validFingerprint(f string) error {
+	if _, ok := profiles[f]; ok || f == FingerprintGo || f == FingerprintMirror {
+		return nil
+	}
+	return fmt.Errorf("upstream: unknown fingerprint %q", f)
+}
+
+// DialTLS connects to addr and performs the TLS handshake with a
+// ClientHello shaped by Config.Fingerprint. With "mirror" it copies
+// clientHello, the raw ClientHello record received from the client, and
+// uses Go's own when that is nil or can't be reproduced. Impersonated
+// ClientHellos keep the ALPN of the profile, minus h2 when cfg.NextProtos
+// leaves it out.
+//
+// Connections are pooled by the proxy, so one opened for a client may
+// later carry another client's requests to the same host.
+func DialTLS(ctx context.Context, addr string, cfg *tls.Config, clientHello []byte) (net.Conn, error) {
+	spec, err := helloSpec(GetConfig().Fingerprint, clientHello, slices.Contains(cfg.NextProtos, "h2"))
+	if err != nil {
+		log.Printf("Cannot impersonate ClientHello for %s, using Go's: %v", addr, err)
+	}
+	if spec == nil {
+		return (&tls.Dialer{Config: cfg}).DialContext(ctx, "tcp", addr)
+	}
+
+	raw, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := utls.UClient(raw, &utls.Config{
+		ServerName: cfg.ServerName,
+		// Verified below, the same way crypto/tls would with cfg
+		InsecureSkipVerify: true,
+	}, utls.HelloCustom)
+	if err := conn.ApplyPreset(spec); err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("apply ClientHello: %w", err)
+	}
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	c := &uconn{UConn: conn, state: connectionState(conn.ConnectionState(), cfg.ServerName)}
+	if err := verifyWith(cfg, c.state); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// helloSpec returns the ClientHello to send for fingerprint, nil meaning
+// Go's default. Without h2, h2 is removed from its ALPN.
+func helloSpec(fingerprint string, clientHello []byte, h2 bool) (*utls.ClientHelloSpec, error) {
+	var spec *utls.ClientHelloSpec
+	switch fingerprint {
+	case FingerprintGo:
+		return nil, nil
+	case FingerprintMirror:
+		if clientHello == nil {
+			return nil, nil
+		}
+		f := &utls.Fingerprinter{AllowBluntMimicry: true}
+		s, err := f.RawClientHello(clientHello)
+		if err != nil {
+			return nil, err
+		}
+		spec = s
+	default:
+		id, ok := profiles[fingerprint]
+		if !ok {
+			return nil, fmt.Errorf("unknown fingerprint %q", fingerprint)
+		}
+		s, err := utls.UTLSIdToSpec(id)
+		if err != nil {
+			return nil, err
+		}
+		spec = &s
+	}
+
+	if h2 {
+		return spec, nil
+	}
+	for _, ext := range spec.Extensions {
+		if alpn, ok := ext.(*utls.ALPNExtension); ok {
+			alpn.AlpnProtocols = slices.DeleteFunc(alpn.AlpnProtocols, func(p string) bool { return p == "h2" })
+			if len(alpn.AlpnProtocols) == 0 {
+				alpn.AlpnProtocols = []string{"http/1.1"}
+			}
+		}
+	}
+	return spec, nil
+}
+
+// uconn exposes the crypto/tls view of a utls connection so that callers
+// can look at the server certificate.
+type uconn struct {
+	*utls.UConn
+	state tls.ConnectionState
+}
+
+func (c *uconn) ConnectionState() tls.ConnectionState { return c.state }
+
+func connectionState(s utls.ConnectionState, serverName string) tls.ConnectionState {
+	return tls.ConnectionState{
+		Version:            s.Version,
+		HandshakeComplete:  s.HandshakeComplete,
+		DidResume:          s.DidResume,
+		CipherSuite:        s.CipherSuite,
+		NegotiatedProtocol: s.NegotiatedProtocol,
+		ServerName:         serverName,
+		PeerCertificates:   s.PeerCertificates,
+		VerifiedChains:     s.VerifiedChains,
+	}
+}
+
+// verifyWith checks cs as crypto/tls would for a client using cfg.
+func verifyWith(cfg *tls.Config, cs tls.ConnectionState) error {
+	if !cfg.InsecureSkipVerify {
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("upstream: no certificate presented")
+		}
+		opts := x509.VerifyOptions{
+			DNSName:       cs.ServerName,
+			Roots:         cfg.RootCAs,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, c := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+		if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+			return err
+		}
+	}
+	if cfg.VerifyConnection != nil {
+		return cfg.VerifyConnection(cs)
+	}
+	return nil
+}
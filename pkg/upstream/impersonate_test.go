@@ -0,0 +1,47 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDialTLSALPN checks that browser profiles keep offering h2 unless
+// the caller can't speak it.
+func TestDialTLSALPN(t *testing.T) {
+	up := httptest.NewUnstartedServer(http.NotFoundHandler())
+	up.EnableHTTP2 = true
+	up.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	up.StartTLS()
+	defer up.Close()
+	addr := strings.TrimPrefix(up.URL, "https://")
+
+	old := GetConfig()
+	defer SetConfig(old)
+	for _, fp := range []string{"chrome", "firefox"} {
+		if err := SetConfig(Config{Fingerprint: fp}); err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range []struct {
+			nextProtos []string
+			want       string
+		}{
+			{[]string{"h2", "http/1.1"}, "h2"},
+			{[]string{"http/1.1"}, "http/1.1"},
+		} {
+			cfg := &tls.Config{InsecureSkipVerify: true, ServerName: "example.com", NextProtos: tt.nextProtos}
+			conn, err := DialTLS(context.Background(), addr, cfg, nil)
+			if err != nil {
+				t.Fatalf("%s: %v", fp, err)
+			}
+			got := conn.(interface{ ConnectionState() tls.ConnectionState }).ConnectionState().NegotiatedProtocol
+			conn.Close()
+			if got != tt.want {
+				t.Errorf("%s offering %v negotiated %q, want %q", fp, tt.nextProtos, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package upstream verifies the TLS certificates of the servers the proxy
+// talks to and shapes the ClientHello it sends them.
+//
+// Certificates are checked against the system roots plus an optional
+// extra CA bundle, for internal servers with a private CA. Hosts listed as
+// insecure are let through anyway. Every verification failure is stored
+// as a finding so bad upstream certificates don't go unnoticed; repeated
+// failures of a host are reported once per ReportInterval.
+//
+// Servers that filter clients by TLS fingerprint reject Go's ClientHello;
+// DialTLS can send a browser's or the proxied client's instead.
+package upstream
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"MITM_PROXY/pkg/match"
+	"MITM_PROXY/pkg/storage"
+)
+
+// Config is the upstream trust configuration.
+type Config struct {
+	CABundle      string   `json:"ca_bundle"`      // path to extra PEM roots
+	InsecureHosts []string `json:"insecure_hosts"` // host globs not verified
+
+	// Fingerprint selects the ClientHello sent upstream: empty for Go's,
+	// "mirror" for the client's, or a browser profile such as "chrome".
+	Fingerprint string `json:"fingerprint"`
+}
+
+var (
+	mu     sync.RWMutex
+	config Config
+	roots  *x509.CertPool
+)
+
+func GetConfig() Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return config
+}
+
+// SetConfig replaces the configuration, loading the CA bundle if any.
+func SetConfig(c Config) error {
+	pool, err := compile(c)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	config, roots = c, pool
+	mu.Unlock()
+	return nil
+}
+
+// Validate checks that c can be used, CA bundle included.
+func Validate(c Config) error {
+	_, err := compile(c)
+	return err
+}
+
+// compile checks c and returns the roots it trusts.
+func compile(c Config) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if c.CABundle != "" {
+		pem, err := os.ReadFile(c.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("upstream: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("upstream: no certificates in %s", c.CABundle)
+		}
+	}
+	for _, h := range c.InsecureHosts {
+		if _, err := path.Match(h, ""); err != nil {
+			return nil, fmt.Errorf("upstream: bad host pattern %q", h)
+		}
+	}
+	if err := validFingerprint(c.Fingerprint); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Load uses the configuration saved through Save, or the CA bundle in
+// bundle if none was saved yet.
+func Load(bundle string) error {
+	stored, err := storage.GetUpstreamTLSConfig()
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return LoadBundle(bundle)
+	}
+	return SetConfig(Config{
+		CABundle:      stored.CABundle,
+		InsecureHosts: stored.InsecureHosts,
+		Fingerprint:   stored.Fingerprint,
+	})
+}
+
+// Save stores c for Load.
+func Save(c Config) error {
+	return storage.SaveUpstreamTLSConfig(&storage.UpstreamTLSConfig{
+		CABundle:      c.CABundle,
+		InsecureHosts: c.InsecureHosts,
+		Fingerprint:   c.Fingerprint,
+	})
+}
+
+// LoadBundle uses the CA bundle in file if it exists.
+func LoadBundle(file string) error {
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		return SetConfig(Config{})
+	}
+	return SetConfig(Config{CABundle: file})
+}
+
+// Insecure reports whether host is exempt from verification.
+func Insecure(host string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, h := range config.InsecureHosts {
+		if match.Host(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// TLSConfig returns a client configuration that verifies upstream
+// certificates according to the current Config.
+func TLSConfig() *tls.Config {
+	return &tls.Config{
+		// Verification is done by VerifyConnection so that the trust
+		// store and exceptions can change at runtime.
+		InsecureSkipVerify: true,
+		VerifyConnection:   VerifyConnection,
+	}
+}
+
+// VerifyConnection checks the certificate chain presented by an upstream.
+func VerifyConnection(cs tls.ConnectionState) error {
+	host := cs.ServerName
+	err := verify(cs)
+	if err == nil {
+		return nil
+	}
+
+	insecure := Insecure(host)
+	if shouldReport(host, err) {
+		go recordError(host, err, insecure)
+	}
+	if insecure {
+		return nil
+	}
+	return fmt.Errorf("upstream certificate for %s: %w", host, err)
+}
+
+func verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no certificate presented")
+	}
+	mu.RLock()
+	pool := roots
+	mu.RUnlock()
+	if pool == nil {
+		pool, _ = x509.SystemCertPool()
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         pool,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// ReportInterval is how long failures of the same kind on the same host
+// are not reported again after one was.
+const ReportInterval = 10 * time.Minute
+
+var (
+	reportMu sync.Mutex
+	reported = map[string]time.Time{} // by host and error kind
+)
+
+// shouldReport tells whether err on host is due to be stored as a
+// finding, so that a failing host doesn't cost a database write per
+// handshake.
+func shouldReport(host string, err error) bool {
+	key := host + "\x00" + errorKind(err)
+	now := time.Now()
+
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	if last, ok := reported[key]; ok && now.Sub(last) < ReportInterval {
+		return false
+	}
+	if len(reported) >= 10000 {
+		for k, last := range reported {
+			if now.Sub(last) >= ReportInterval {
+				delete(reported, k)
+			}
+		}
+	}
+	reported[key] = now
+	return true
+}
+
+func recordError(host string, err error, insecure bool) {
+	detail := err.Error()
+	if insecure {
+		detail += " (host is exempt from verification)"
+	}
+	f := &storage.Finding{
+		Source:     "upstream",
+		Type:       "upstream-tls-" + errorKind(err),
+		Severity:   "medium",
+		Confidence: "certain",
+		Host:       host,
+		Detail:     detail,
+	}
+	if _, err := storage.SaveFinding(f); err != nil {
+		log.Printf("Error saving upstream TLS finding for %s: %v", host, err)
+	}
+}
+
+// errorKind names the class of a verification error for deduplication.
+func errorKind(err error) string {
+	var unknown x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	switch {
+	case errors.As(err, &unknown):
+		return "unknown-authority"
+	case errors.As(err, &hostname):
+		return "hostname-mismatch"
+	case errors.As(err, &invalid):
+		if invalid.Reason == x509.Expired {
+			return "expired"
+		}
+		return "invalid"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,31 @@
+package upstream
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShouldReport(t *testing.T) {
+	defer func() { reported = map[string]time.Time{} }()
+	unknown := x509.UnknownAuthorityError{}
+	other := errors.New("no certificate presented")
+
+	if !shouldReport("a.test", unknown) {
+		t.Fatal("first failure not reported")
+	}
+	if shouldReport("a.test", unknown) {
+		t.Error("repeated failure reported again")
+	}
+	if !shouldReport("a.test", other) {
+		t.Error("failure of another kind not reported")
+	}
+	if !shouldReport("b.test", unknown) {
+		t.Error("failure of another host not reported")
+	}
+	reported["a.test\x00"+errorKind(unknown)] = time.Now().Add(-ReportInterval)
+	if !shouldReport("a.test", unknown) {
+		t.Error("failure not reported again after ReportInterval")
+	}
+}